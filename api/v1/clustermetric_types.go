@@ -19,6 +19,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ClusterMetricSpec defines the desired state of ClusterMetric
+type ClusterMetricSpec struct {
+	MetricSpec `json:",inline"`
+
+	// NamespaceSelector restricts the watched objects to namespaces matching this label selector.
+	// Metrics with a namespace selector get their own metric names, prefixed with the name of the ClusterMetric
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
 // ClusterMetricStatus defines the observed state of ClusterMetric
 type ClusterMetricStatus struct {
 	MetricBaseName   *string            `json:"metricBaseName,omitempty"`
@@ -28,14 +37,17 @@ type ClusterMetricStatus struct {
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+//+kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ClusterMetric is the Schema for the clustermetrics API
 type ClusterMetric struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   MetricSpec   `json:"spec,omitempty"`
-	Status MetricStatus `json:"status,omitempty"`
+	Spec   ClusterMetricSpec `json:"spec,omitempty"`
+	Status MetricStatus      `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
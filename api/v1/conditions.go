@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons a metric is or is not ready.
+const (
+	ReasonWatching            xpv1.ConditionReason = "Watching"
+	ReasonNoMatchingCRDs      xpv1.ConditionReason = "NoMatchingCRDs"
+	ReasonInvalidRegex        xpv1.ConditionReason = "InvalidRegex"
+	ReasonInvalidSelector     xpv1.ConditionReason = "InvalidSelector"
+	ReasonInvalidSpec         xpv1.ConditionReason = "InvalidSpec"
+	ReasonCRDListFailed       xpv1.ConditionReason = "CRDListFailed"
+	ReasonNamespaceListFailed xpv1.ConditionReason = "NamespaceListFailed"
+	ReasonWatchFailed         xpv1.ConditionReason = "WatchFailed"
+)
+
+// Watching returns a condition that indicates the metric has metric stores
+// for all matching resources.
+func Watching() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonWatching,
+	}
+}
+
+// NoMatchingCRDs returns a condition that indicates no CRD matches the metric.
+func NoMatchingCRDs() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoMatchingCRDs,
+	}
+}
+
+// Unavailable returns a condition that indicates the metric could not be
+// set up for the supplied reason.
+func Unavailable(reason xpv1.ConditionReason, err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            err.Error(),
+	}
+}
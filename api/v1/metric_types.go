@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -32,17 +33,63 @@ type MetricSpec struct {
 
 	// Categories contains an object to add metrics for crds by crd category. Categories are only evaluated, if MatchName is nil
 	Categories *MetricCategory `json:"categories,omitempty"`
+
+	// InfoLabels lists fields of the watched objects that are exposed as labels on the _info metric
+	InfoLabels *[]InfoLabel `json:"infoLabels,omitempty"`
+
+	// ValueMetrics lists additional metrics with values extracted from fields of the watched objects
+	ValueMetrics *[]ValueMetric `json:"valueMetrics,omitempty"`
+
+	// ConditionTypes restricts the _condition metrics to the listed condition types. All conditions are exposed, if empty
+	ConditionTypes *[]string `json:"conditionTypes,omitempty"`
+
+	// ReadyMessage adds the message of the Ready condition as label to the _ready_info metric
+	ReadyMessage *ConditionMessage `json:"readyMessage,omitempty"`
+
+	// Selector restricts the watched objects to objects matching this label selector.
+	// Metrics with a selector get their own metric names, prefixed with the name of the Metric
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// FieldSelector restricts the watched objects to objects matching this field selector, e.g. metadata.name=foo.
+	// Only the metadata.name and metadata.namespace fields are supported.
+	// Metrics with a field selector get their own metric names, prefixed with the name of the Metric
+	FieldSelector *string `json:"fieldSelector,omitempty"`
+
+	// VersionlessNames omits the crd version from metric names. If the storage version of a crd changes,
+	// the metric store is migrated to the new version in place and keeps its metric series
+	VersionlessNames bool `json:"versionlessNames,omitempty"`
+
+	// Mode Metadata watches only the metadata of the objects, which needs less memory for large or many objects.
+	// Only the base, _created, _labels and _info metrics are exposed, info labels can only be read from the metadata.
+	// The store is shared with other metric objects, only if all of them use Metadata
+	// +kubebuilder:default:=Full
+	Mode MetricMode `json:"mode,omitempty"`
+
+	// ResyncPeriod overrides the resync period of the manager for the watched resources, e.g. 10m.
+	// Stores shared with other metric objects resync with the shortest period of them
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod,omitempty"`
 }
 
 // MetricStatus defines the observed state of Metric
 type MetricStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// ObservedGeneration is the latest metadata.generation reconciled by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	MetricBaseName   *string            `json:"metricBaseName,omitempty"`
 	WatchedResources *[]WatchedResource `json:"watchedResources,omitempty"`
+
+	// MetricsPath is the path on the metrics server serving only the metrics of this object
+	MetricsPath *string `json:"metricsPath,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+//+kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Metric is the Schema for the Metrics API
 type Metric struct {
@@ -83,6 +130,64 @@ type MetricCategory struct {
 	Join MetricJoin `json:"join,omitempty"`
 }
 
+type InfoLabel struct {
+	// FieldPath is the path of the field in the watched object, e.g. spec.forProvider.region or metadata.annotations['crossplane.io/external-name']
+	FieldPath string `json:"fieldPath"`
+
+	// Label is the name of the label the value of the field is exposed as. The labels name and namespace are reserved
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Label string `json:"label"`
+}
+
+// +kubebuilder:validation:Enum=Full;Metadata
+type MetricMode string
+
+const (
+	ModeFull     MetricMode = "Full"
+	ModeMetadata MetricMode = "Metadata"
+)
+
+// +kubebuilder:validation:Enum=gauge;counter
+type ValueMetricType string
+
+const (
+	ValueMetricGauge   ValueMetricType = "gauge"
+	ValueMetricCounter ValueMetricType = "counter"
+)
+
+type ValueMetric struct {
+	// Name is appended to the metric name of the watched resource, e.g. allocated_storage.
+	// It must differ from the other value metrics and from the metrics of every resource, like ready or info
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Name string `json:"name"`
+
+	// FieldPath is the path of the field the value is read from. Numbers, booleans, quantities and RFC3339 timestamps are supported
+	FieldPath string `json:"fieldPath"`
+
+	// Labels lists fields of the watched objects that are exposed as additional labels of the metric
+	Labels *[]InfoLabel `json:"labels,omitempty"`
+
+	// Help is the help text of the metric
+	Help *string `json:"help,omitempty"`
+
+	// Type is the prometheus type of the metric. Counters are exposed as counters in OpenMetrics,
+	// if their name ends with _total, otherwise with type unknown
+	// +kubebuilder:default:=gauge
+	Type ValueMetricType `json:"type,omitempty"`
+
+	// Unit of the value, e.g. seconds or bytes. The unit is appended to the name, if the name does not end with it,
+	// and exposed as unit metadata in OpenMetrics
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Unit *string `json:"unit,omitempty"`
+}
+
+type ConditionMessage struct {
+	// MaxLength caps the length of the message label, longer messages are truncated
+	// +kubebuilder:default:=128
+	// +kubebuilder:validation:Minimum=1
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
 type WatchedResource struct {
 	Group      string  `json:"group"`
 	Version    string  `json:"version"`
@@ -22,6 +22,7 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -84,6 +85,27 @@ func (in *ClusterMetricList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterMetricSpec) DeepCopyInto(out *ClusterMetricSpec) {
+	*out = *in
+	in.MetricSpec.DeepCopyInto(&out.MetricSpec)
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterMetricSpec.
+func (in *ClusterMetricSpec) DeepCopy() *ClusterMetricSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterMetricSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterMetricStatus) DeepCopyInto(out *ClusterMetricStatus) {
 	*out = *in
@@ -115,6 +137,36 @@ func (in *ClusterMetricStatus) DeepCopy() *ClusterMetricStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionMessage) DeepCopyInto(out *ConditionMessage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionMessage.
+func (in *ConditionMessage) DeepCopy() *ConditionMessage {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionMessage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InfoLabel) DeepCopyInto(out *InfoLabel) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfoLabel.
+func (in *InfoLabel) DeepCopy() *InfoLabel {
+	if in == nil {
+		return nil
+	}
+	out := new(InfoLabel)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metric) DeepCopyInto(out *Metric) {
 	*out = *in
@@ -225,6 +277,55 @@ func (in *MetricSpec) DeepCopyInto(out *MetricSpec) {
 		*out = new(MetricCategory)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.InfoLabels != nil {
+		in, out := &in.InfoLabels, &out.InfoLabels
+		*out = new([]InfoLabel)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]InfoLabel, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.ValueMetrics != nil {
+		in, out := &in.ValueMetrics, &out.ValueMetrics
+		*out = new([]ValueMetric)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]ValueMetric, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.ConditionTypes != nil {
+		in, out := &in.ConditionTypes, &out.ConditionTypes
+		*out = new([]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]string, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.ReadyMessage != nil {
+		in, out := &in.ReadyMessage, &out.ReadyMessage
+		*out = new(ConditionMessage)
+		**out = **in
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FieldSelector != nil {
+		in, out := &in.FieldSelector, &out.FieldSelector
+		*out = new(string)
+		**out = **in
+	}
+	if in.ResyncPeriod != nil {
+		in, out := &in.ResyncPeriod, &out.ResyncPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricSpec.
@@ -240,6 +341,7 @@ func (in *MetricSpec) DeepCopy() *MetricSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricStatus) DeepCopyInto(out *MetricStatus) {
 	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
 	if in.MetricBaseName != nil {
 		in, out := &in.MetricBaseName, &out.MetricBaseName
 		*out = new(string)
@@ -256,6 +358,11 @@ func (in *MetricStatus) DeepCopyInto(out *MetricStatus) {
 			}
 		}
 	}
+	if in.MetricsPath != nil {
+		in, out := &in.MetricsPath, &out.MetricsPath
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricStatus.
@@ -268,6 +375,40 @@ func (in *MetricStatus) DeepCopy() *MetricStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValueMetric) DeepCopyInto(out *ValueMetric) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = new([]InfoLabel)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]InfoLabel, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.Help != nil {
+		in, out := &in.Help, &out.Help
+		*out = new(string)
+		**out = **in
+	}
+	if in.Unit != nil {
+		in, out := &in.Unit, &out.Unit
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValueMetric.
+func (in *ValueMetric) DeepCopy() *ValueMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(ValueMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WatchedResource) DeepCopyInto(out *WatchedResource) {
 	*out = *in
@@ -18,7 +18,10 @@ package main
 
 import (
 	"flag"
+	"math"
+	"net/http"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,16 +30,21 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 
 	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
 	controllers "github.com/crossplane-contrib/x-metrics/pkg/controller/metric"
 	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
 
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	//+kubebuilder:scaffold:imports
@@ -60,11 +68,32 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var maxConcurrentReconciles int
+	var metricsAuth bool
+	var cacheMetrics bool
+	var listPageSize int64
+	var resyncPeriod time.Duration
+	var watchBackoff time.Duration
+	var watchBackoffMax time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "The maximum number of metrics reconciled in parallel by each controller.")
+	flag.BoolVar(&metricsAuth, "metrics-auth", false,
+		"Require a bearer token for the x-metrics endpoint. "+
+			"The token is authenticated with a TokenReview and the metrics are scoped to the namespaces the user can list the resources in.")
+	flag.BoolVar(&cacheMetrics, "cache-metrics", false,
+		"Keep the serialized and the gzip compressed metrics of each metric store and only serialize them again after objects changed. "+
+			"Speeds up scrapes at the cost of memory.")
+	flag.Int64Var(&listPageSize, "list-page-size", 500,
+		"The number of objects requested per page when listing the watched resources. 0 keeps the default paging of client-go.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 0,
+		"The period the objects of the watched resources are resynced with, if the metric object does not set its own. 0 disables resyncs.")
+	flag.DurationVar(&watchBackoff, "watch-backoff", time.Second,
+		"The initial delay before a failed list or watch of a watched resource is retried, doubled after each failure. 0 retries with the default backoff of client-go only.")
+	flag.DurationVar(&watchBackoffMax, "watch-backoff-max", 5*time.Minute, "The maximum delay before a failed list or watch of a watched resource is retried.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -93,28 +122,66 @@ func main() {
 		setupLog.Error(err, "unable to set dynamic client")
 		os.Exit(1)
 	}
-	mm := xmetrics.NewManagedMetricsHandler(dc)
-
-	err = mgr.AddMetricsExtraHandler("/x-metrics", &mm)
+	newStore := store.NewXMetricsStore
+	if cacheMetrics {
+		newStore = store.NewCachedXMetricsStore
+	}
+	mm := xmetrics.NewManagedMetricsHandlerWithStore(dc, newStore)
+	mm.SetConsumerLookup(controllers.MetricStoresOf)
+	mm.ListPageSize = listPageSize
+	mm.ResyncPeriod = resyncPeriod
+	mm.WatchBackoff = wait.Backoff{
+		Duration: watchBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      watchBackoffMax,
+	}
+	mm.MetadataClient, err = metadata.NewForConfig(conf)
 	if err != nil {
-		setupLog.Error(err, "unable to setup handler")
+		setupLog.Error(err, "unable to set metadata client")
+		os.Exit(1)
+	}
+
+	if err := metrics.Registry.Register(mm.Collector()); err != nil {
+		setupLog.Error(err, "unable to register metric store collector")
 		os.Exit(1)
 	}
 
+	var metricsHandler http.Handler = &mm
+	if metricsAuth {
+		cs, err := kubernetes.NewForConfig(conf)
+		if err != nil {
+			setupLog.Error(err, "unable to set kubernetes client")
+			os.Exit(1)
+		}
+		metricsHandler = xmetrics.NewAuthHandler(cs, metricsHandler)
+	}
+
+	// the subtree serves the metrics of single metric objects
+	for _, path := range []string{xmetrics.MetricsPath, xmetrics.MetricsPath + "/"} {
+		if err := mgr.AddMetricsExtraHandler(path, metricsHandler); err != nil {
+			setupLog.Error(err, "unable to setup handler")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.MetricReconciler{
-		Kind:      "Metric",
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		MmHandler: &mm,
+		Kind:                    "Metric",
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MmHandler:               &mm,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Metric")
 		os.Exit(1)
 	}
 	if err = (&controllers.MetricReconciler{
-		Kind:      "ClusterMetric",
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		MmHandler: &mm,
+		Kind:                    "ClusterMetric",
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MmHandler:               &mm,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Clustermetric")
 		os.Exit(1)
@@ -125,7 +192,8 @@ func main() {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// the pod is ready while all metric stores are synced, the unsynced stores are listed on /readyz/stores
+	if err := mgr.AddReadyzCheck("stores", controllers.NewReadyGate(mgr.GetClient(), &mm).Check); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -18,21 +18,34 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
 	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -42,6 +55,8 @@ type MetricReconciler struct {
 	Kind      string
 	Scheme    *runtime.Scheme
 	MmHandler xmetrics.IManagedMetricsHandler
+	// MaxConcurrentReconciles is the maximum number of metrics reconciled in parallel, defaults to 1
+	MaxConcurrentReconciles int
 }
 
 type Resource struct {
@@ -63,18 +78,52 @@ type CloseChannel struct {
 }
 
 type MetricsMemory struct {
-	Channel  *CloseChannel
-	Consumer map[string]struct{}
+	Channel   *CloseChannel
+	Consumer  map[string]xmetrics.StoreOptions
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Options   xmetrics.StoreOptions
 }
 
 const (
 	finalizerName = "metrics.crossplane.io/finalizer"
+	// crds and namespaces are watched, the periodic requeue is only a safety net
+	requeueAfter = time.Minute * 30
 )
 
+// reasonError is an error with the reason reported in the Ready condition of a metric
+type reasonError struct {
+	reason xpv1.ConditionReason
+	error
+}
+
 var (
-	metricsMemory = map[string]*MetricsMemory{}
+	metricsMemory = newMetricsRegistry()
 )
 
+// consumerName returns the name of a Metric in the consumer bookkeeping of the metric stores,
+// the namespace of a ClusterMetric is empty
+func consumerName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "::" + name
+}
+
+// splitConsumerName returns the namespace and the name of a consumer, the namespace of a ClusterMetric is empty
+func splitConsumerName(consumer string) (string, string) {
+	if namespace, name, ok := strings.Cut(consumer, "::"); ok {
+		return namespace, name
+	}
+	return "", consumer
+}
+
+// MetricStoresOf returns the names of the metric stores consumed by a Metric,
+// or by a ClusterMetric if the namespace is empty. It implements xmetrics.ConsumerLookup.
+func MetricStoresOf(namespace string, name string) []string {
+	return metricsMemory.currentMetrics(consumerName(namespace, name))
+}
+
 func (r *MetricReconciler) newReconciler() (client.Object, error) {
 	metricGVK := metricsv1.GroupVersion.WithKind(r.Kind)
 	ro, err := r.Scheme.New(metricGVK)
@@ -87,10 +136,13 @@ func (r *MetricReconciler) newReconciler() (client.Object, error) {
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=metrics.crossplane.io,resources=metrics/finalizers,verbs=update
-// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	log := log.FromContext(ctx)
+	// the ready gate waits for the metric objects existing at startup, including the ones deleted meanwhile
+	defer metricsMemory.markReconciled(consumerName(req.Namespace, req.Name))
 	metric, err := r.newReconciler()
 	if err != nil {
 		log.Error(err, "Unrecognised metric type")
@@ -110,16 +162,12 @@ func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		log.Error(fmt.Errorf("unexpected metric type: %t", metric), "Not retrying.")
 		return ctrl.Result{}, nil
 	}
-	var currentConsumerName string
 	var currentNamespace string
 	if namespaced {
-		currentConsumerName = metric.GetNamespace() + "::" + metric.GetName()
 		currentNamespace = metric.GetNamespace()
-
-	} else {
-		currentConsumerName = metric.GetName()
 	}
-	currentMetrics := getCurrentMetrics(currentConsumerName)
+	currentConsumerName := consumerName(currentNamespace, metric.GetName())
+	currentMetrics := metricsMemory.currentMetrics(currentConsumerName)
 
 	objectMeta, metricSpec, metricStatus, _ := getSpecAndStatus(metric)
 
@@ -136,19 +184,58 @@ func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	} else {
 		// If the object is marked for deletion, run the cleanup, if a finaliser is set
 		if controllerutil.ContainsFinalizer(metric, finalizerName) {
-			cleanupMetrics(r.MmHandler, currentMetrics, currentConsumerName)
+			metricsMemory.cleanup(r.MmHandler, currentMetrics, currentConsumerName)
 			controllerutil.RemoveFinalizer(metric, finalizerName)
 			if err := r.Update(ctx, metric); err != nil {
 				return ctrl.Result{}, nil
 			}
+			r.recordOutcome(outcomeDeleted)
 			return ctrl.Result{}, nil
 		}
 
 	}
 
-	resourceList, err := r.getGVRForMetric(ctx, metricSpec, namespaced)
+	storeOptions, err := getStoreOptions(metricSpec)
+	if err != nil {
+		log.Error(err, "invalid metric spec")
+		reason := metricsv1.ReasonInvalidSelector
+		var rErr *reasonError
+		if errors.As(err, &rErr) {
+			reason = rErr.reason
+		}
+		return r.updateUnavailableStatus(ctx, metric, metricStatus, reason, err)
+	}
 
-	addR, _, deleteR := r.getResources(ctx, &currentMetrics, resourceList)
+	var namespaceSelector string
+	if clusterMetric, ok := metric.(*metricsv1.ClusterMetric); ok && clusterMetric.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(clusterMetric.Spec.NamespaceSelector)
+		if err != nil {
+			log.Error(err, "invalid namespace selector")
+			return r.updateUnavailableStatus(ctx, metric, metricStatus, metricsv1.ReasonInvalidSelector, err)
+		}
+		namespaceSelector = selector.String()
+		namespaces, err := r.getSelectedNamespaces(ctx, clusterMetric.Spec.NamespaceSelector)
+		if err != nil {
+			log.Error(err, "unable to get selected namespaces")
+			return r.updateUnavailableStatus(ctx, metric, metricStatus, metricsv1.ReasonNamespaceListFailed, err)
+		}
+		storeOptions.NamespaceFilter = true
+		storeOptions.Namespaces = namespaces
+	}
+
+	metricPrefix := storePrefix(currentNamespace, metric.GetName(), storeOptions, namespaceSelector)
+	resourceList, err := r.getGVRForMetric(ctx, metricSpec, namespaced, metricPrefix)
+	if err != nil {
+		log.Error(err, "unable to get resources")
+		reason := xpv1.ReasonReconcileError
+		var rErr *reasonError
+		if errors.As(err, &rErr) {
+			reason = rErr.reason
+		}
+		return r.updateUnavailableStatus(ctx, metric, metricStatus, reason, err)
+	}
+
+	addR, currentR, deleteR := r.getResources(ctx, &currentMetrics, resourceList)
 
 	var statusMetrics []metricsv1.WatchedResource
 	if metricStatus.WatchedResources != nil {
@@ -159,25 +246,12 @@ func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if len(addR) > 0 {
 		for _, v := range addR {
 			metricName := v.MetricName
-			if _, ok := metricsMemory[metricName]; !ok {
-				gvr := schema.GroupVersionResource{
-					Group:    v.Group,
-					Version:  v.Version,
-					Resource: v.Resource,
-				}
-				channel := r.MmHandler.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, currentNamespace)
-				metricsMemory[metricName] = &MetricsMemory{
-					Consumer: map[string]struct{}{
-						currentConsumerName: {},
-					},
-					Channel: &CloseChannel{
-						Channel: channel,
-						Closed:  false,
-					},
-				}
-			} else {
-				metricsMemory[metricName].Consumer[currentConsumerName] = struct{}{}
+			gvr := schema.GroupVersionResource{
+				Group:    v.Group,
+				Version:  v.Version,
+				Resource: v.Resource,
 			}
+			metricsMemory.join(ctx, r.MmHandler, metricName, gvr, currentNamespace, currentConsumerName, storeOptions)
 
 			statusMetrics = append(statusMetrics, metricsv1.WatchedResource{
 				Kind:       v.Kind,
@@ -189,22 +263,67 @@ func (r *MetricReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	}
 
+	for _, metricName := range currentR {
+		v := (*resourceList)[metricName]
+		gvr := schema.GroupVersionResource{
+			Group:    v.Group,
+			Version:  v.Version,
+			Resource: v.Resource,
+		}
+		if metricsMemory.update(ctx, r.MmHandler, metricName, gvr, currentConsumerName, storeOptions) {
+			statusMetrics = updateWatchedVersion(statusMetrics, metricName, v.Version)
+		}
+	}
+
 	if len(deleteR) > 0 {
-		cleanupMetrics(r.MmHandler, deleteR, currentConsumerName)
+		metricsMemory.cleanup(r.MmHandler, deleteR, currentConsumerName)
 		statusMetrics = filterDeletedMetrics(&statusMetrics, &deleteR)
 	}
-	if err != nil {
-		log.Error(err, "unable to get resources")
-	}
 	metricStatus.WatchedResources = &statusMetrics
+	metricsPath := xmetrics.ConsumerPath(currentNamespace, metric.GetName())
+	metricStatus.MetricsPath = &metricsPath
+	if len(*resourceList) > 0 {
+		metricStatus.SetConditions(metricsv1.Watching(), xpv1.ReconcileSuccess())
+		if err := r.watchError(currentConsumerName); err != nil {
+			metricStatus.SetConditions(metricsv1.Unavailable(metricsv1.ReasonWatchFailed, err))
+		}
+	} else {
+		metricStatus.SetConditions(metricsv1.NoMatchingCRDs(), xpv1.ReconcileSuccess())
+	}
+	metricStatus.ObservedGeneration = metric.GetGeneration()
 	if err := r.Client.Status().Update(ctx, metric); err != nil {
 		log.Error(err, "unable to update metric status")
 	}
+	r.recordOutcome(metricStatus.GetCondition(xpv1.TypeReady).Reason)
 
-	duration := time.Minute * 5
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 
-	return ctrl.Result{RequeueAfter: duration}, nil
+}
 
+// updateUnavailableStatus reports the error in the conditions of the metric.
+// Metric stores registered in earlier reconciles are kept.
+func (r *MetricReconciler) updateUnavailableStatus(ctx context.Context, metric client.Object, metricStatus *metricsv1.MetricStatus, reason xpv1.ConditionReason, err error) (ctrl.Result, error) {
+	metricStatus.SetConditions(metricsv1.Unavailable(reason, err), xpv1.ReconcileError(err))
+	metricStatus.ObservedGeneration = metric.GetGeneration()
+	if err := r.Client.Status().Update(ctx, metric); err != nil {
+		log.FromContext(ctx).Error(err, "unable to update metric status")
+	}
+	r.recordOutcome(reason)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// watchError returns the first error of the watches of the metric stores of the consumer
+func (r *MetricReconciler) watchError(consumer string) error {
+	for _, metricName := range metricsMemory.currentMetrics(consumer) {
+		memory, ok := metricsMemory.get(metricName)
+		if !ok {
+			continue
+		}
+		if err := r.MmHandler.WatchError(memory.GVR); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", memory.GVR.String(), err)
+		}
+	}
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -213,46 +332,170 @@ func (r *MetricReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err != nil {
 		return err
 	}
-	return ctrl.NewControllerManagedBy(mgr).
+	watchStates := make(chan event.GenericEvent)
+	r.MmHandler.NotifyWatchState(func(gvr schema.GroupVersionResource) {
+		r.enqueueConsumersOf(gvr, watchStates)
+	})
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(reconcilerType).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		// failing and recovering watches of the metric stores are reported in the conditions of their consumers
+		Watches(&source.Channel{Source: watchStates}, &handler.EnqueueRequestForObject{}).
+		// installs, upgrades and removals of crds are reflected without waiting for the periodic requeue
+		Watches(&source.Kind{Type: &apiextensions.CustomResourceDefinition{}}, handler.EnqueueRequestsFromMapFunc(r.metricsForCRD), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	if _, ok := reconcilerType.(*metricsv1.ClusterMetric); ok {
+		// namespace selectors of cluster metrics need to be evaluated again, if namespaces change
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.clusterMetricsForNamespace))
+	}
+	return controllerBuilder.Complete(r)
+}
+
+// enqueueConsumersOf sends an event for each metric object of the kind of the reconciler consuming a metric store of the gvr.
+// The events are sent without blocking the caller.
+func (r *MetricReconciler) enqueueConsumersOf(gvr schema.GroupVersionResource, events chan<- event.GenericEvent) {
+	var objects []client.Object
+	for _, consumer := range metricsMemory.consumersOf(gvr) {
+		namespace, name := splitConsumerName(consumer)
+		if (namespace != "") != (r.Kind == "Metric") {
+			continue
+		}
+		obj, err := r.newReconciler()
+		if err != nil {
+			return
+		}
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		objects = append(objects, obj)
+	}
+	if len(objects) == 0 {
+		return
+	}
+	go func() {
+		for _, obj := range objects {
+			events <- event.GenericEvent{Object: obj}
+		}
+	}()
 }
 
-func (r *MetricReconciler) getGVRForMetric(ctx context.Context, metric *metricsv1.MetricSpec, namespaced bool) (*map[string]Resource, error) {
+// metricsForCRD returns a request for each metric of the reconciled kind that matches the crd
+func (r *MetricReconciler) metricsForCRD(obj client.Object) []reconcile.Request {
+	crd, ok := obj.(*apiextensions.CustomResourceDefinition)
+	if !ok {
+		return nil
+	}
+	var list client.ObjectList
+	switch r.Kind {
+	case "Metric":
+		list = &metricsv1.MetricList{}
+	case "ClusterMetric":
+		list = &metricsv1.ClusterMetricList{}
+	default:
+		return nil
+	}
+	if err := r.Client.List(context.Background(), list); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	addRequest := func(metric client.Object, spec *metricsv1.MetricSpec, namespaced bool) {
+		var matchName *regexp.Regexp
+		if spec.MatchName != nil {
+			var err error
+			if matchName, err = regexp.Compile(*spec.MatchName); err != nil {
+				return
+			}
+		}
+		if matchesCRD(crd, spec, matchName, namespaced) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: metric.GetName(), Namespace: metric.GetNamespace()},
+			})
+		}
+	}
+	switch l := list.(type) {
+	case *metricsv1.MetricList:
+		for i := range l.Items {
+			addRequest(&l.Items[i], &l.Items[i].Spec, true)
+		}
+	case *metricsv1.ClusterMetricList:
+		for i := range l.Items {
+			addRequest(&l.Items[i], &l.Items[i].Spec.MetricSpec, false)
+		}
+	}
+	return requests
+}
+
+// clusterMetricsForNamespace returns a request for each ClusterMetric with a namespace selector
+func (r *MetricReconciler) clusterMetricsForNamespace(obj client.Object) []reconcile.Request {
+	var metrics metricsv1.ClusterMetricList
+	if err := r.Client.List(context.Background(), &metrics); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, m := range metrics.Items {
+		if m.Spec.NamespaceSelector != nil {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: m.GetName()},
+			})
+		}
+	}
+	return requests
+}
+
+// getSelectedNamespaces returns the sorted names of all namespaces matching the selector
+func (r *MetricReconciler) getSelectedNamespaces(ctx context.Context, namespaceSelector *metav1.LabelSelector) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	var list corev1.NamespaceList
+	if err := r.Client.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.GetName())
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (r *MetricReconciler) getGVRForMetric(ctx context.Context, metric *metricsv1.MetricSpec, namespaced bool, metricPrefix string) (*map[string]Resource, error) {
 
 	list := map[string]Resource{}
 
+	var matchName *regexp.Regexp
+	if metric.MatchName != nil {
+		var err error
+		if matchName, err = regexp.Compile(*metric.MatchName); err != nil {
+			return nil, &reasonError{reason: metricsv1.ReasonInvalidRegex, error: err}
+		}
+	}
+
 	var crds apiextensions.CustomResourceDefinitionList
 	options := client.ListOptions{}
 	if err := r.Client.List(ctx, &crds, &options); err != nil {
-		return nil, err
+		return nil, &reasonError{reason: metricsv1.ReasonCRDListFailed, error: err}
 	}
-	if metric.MatchName != nil || metric.Categories != nil {
-		for _, crd := range crds.Items {
-			name := crd.GetName()
-			match := true
-			if metric.MatchName != nil {
-				match, _ = regexp.MatchString(*metric.MatchName, name)
-			} else if metric.Categories != nil {
-				crdCategories := crd.Spec.Names.Categories
-				match = matchesCategories(crdCategories, metric.Categories.Values, metric.Categories.Join)
-			}
-			inNameList := inList(metric.IncludeNames, name)
-			inExcludeList := inList(metric.ExcludeNames, name)
-			inNamespace := isNamespaced(&crd)
-			// if we need a gvr for a metrics resource, we only watch namespaced resources
-			if (match || inNameList) && !inExcludeList && (namespaced == inNamespace || !namespaced) {
-				for _, version := range crd.Spec.Versions {
-					if version.Storage {
-						metricName := xmetrics.GetValidLabel(crd.Spec.Group + "_" + crd.Spec.Names.Kind + "_" + version.Name)
-						list[metricName] = Resource{
-							Group:      crd.Spec.Group,
-							Version:    version.Name,
-							Resource:   crd.Spec.Names.Plural,
-							Kind:       crd.Spec.Names.Kind,
-							MetricName: metricName,
-						}
-					}
+	for _, crd := range crds.Items {
+		if !matchesCRD(&crd, metric, matchName, namespaced) {
+			continue
+		}
+		for _, version := range crd.Spec.Versions {
+			if version.Storage {
+				metricName := crd.Spec.Group + "_" + crd.Spec.Names.Kind
+				if !metric.VersionlessNames {
+					metricName = metricName + "_" + version.Name
+				}
+				if metricPrefix != "" {
+					metricName = metricPrefix + "_" + metricName
+				}
+				metricName = xmetrics.GetValidLabel(metricName)
+				list[metricName] = Resource{
+					Group:      crd.Spec.Group,
+					Version:    version.Name,
+					Resource:   crd.Spec.Names.Plural,
+					Kind:       crd.Spec.Names.Kind,
+					MetricName: metricName,
 				}
 			}
 		}
@@ -260,6 +503,26 @@ func (r *MetricReconciler) getGVRForMetric(ctx context.Context, metric *metricsv
 	return &list, nil
 }
 
+// matchesCRD returns true, if the metric adds metrics for the crd
+func matchesCRD(crd *apiextensions.CustomResourceDefinition, metric *metricsv1.MetricSpec, matchName *regexp.Regexp, namespaced bool) bool {
+	if metric.MatchName == nil && metric.Categories == nil {
+		return false
+	}
+	name := crd.GetName()
+	match := true
+	if matchName != nil {
+		match = matchName.MatchString(name)
+	} else if metric.Categories != nil {
+		crdCategories := crd.Spec.Names.Categories
+		match = matchesCategories(crdCategories, metric.Categories.Values, metric.Categories.Join)
+	}
+	inNameList := inList(metric.IncludeNames, name)
+	inExcludeList := inList(metric.ExcludeNames, name)
+	inNamespace := isNamespaced(crd)
+	// if we need a gvr for a metrics resource, we only watch namespaced resources
+	return (match || inNameList) && !inExcludeList && (namespaced == inNamespace || !namespaced)
+}
+
 func matchesCategories(current []string, wanted []string, joinType metricsv1.MetricJoin) bool {
 	contains := false
 	for _, w := range wanted {
@@ -280,34 +543,109 @@ func matchesCategories(current []string, wanted []string, joinType metricsv1.Met
 	return contains
 }
 
-func getCurrentMetrics(cMetricName string) []string {
-
-	currentMetrics := []string{}
-	for metricName, metric := range metricsMemory {
-		for clusertMetricName := range metric.Consumer {
-			if clusertMetricName == cMetricName {
-				currentMetrics = append(currentMetrics, metricName)
-			}
+func updateWatchedVersion(metrics []metricsv1.WatchedResource, metricName string, version string) []metricsv1.WatchedResource {
+	for i := range metrics {
+		if metrics[i].MetricName != nil && *metrics[i].MetricName == metricName {
+			metrics[i].Version = version
 		}
 	}
-	return currentMetrics
+	return metrics
 }
 
-func cleanupMetrics(handler xmetrics.IManagedMetricsHandler, metrics []string, currentConsumer string) {
-	for _, metricName := range metrics {
-		if metric, ok := metricsMemory[metricName]; ok {
-			if !metric.Channel.Closed {
-				delete(metric.Consumer, currentConsumer)
-				if len(metric.Consumer) == 0 {
-					close(metric.Channel.Channel)
-					metric.Channel.Closed = true
-					handler.RemoveMetricStore(metricName)
-					delete(metricsMemory, metricName)
-				}
+// mergeConsumerOptions merges the store options of all consumers in a stable order
+func mergeConsumerOptions(consumer map[string]xmetrics.StoreOptions) xmetrics.StoreOptions {
+	names := make([]string, 0, len(consumer))
+	for name := range consumer {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	options := make([]xmetrics.StoreOptions, 0, len(names))
+	for _, name := range names {
+		options = append(options, consumer[name])
+	}
+	return xmetrics.MergeStoreOptions(options...)
+}
+
+// storePrefix returns the prefix of the names of the metric stores of a consumer.
+// Stores are only shared by consumers watching the same objects, so the prefix contains the namespace of a Metric.
+// The objects of a consumer with selectors are its own, its stores are prefixed with its name, too.
+// The prefix is empty for ClusterMetrics without selectors.
+func storePrefix(namespace string, name string, opts xmetrics.StoreOptions, namespaceSelector string) string {
+	var parts []string
+	if namespace != "" {
+		parts = append(parts, namespace)
+	}
+	if opts.LabelSelector != "" || opts.FieldSelector != "" || namespaceSelector != "" {
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, "_")
+}
+
+func getStoreOptions(metric *metricsv1.MetricSpec) (xmetrics.StoreOptions, error) {
+	options := xmetrics.StoreOptions{
+		InfoMappings: getInfoMappings(metric.InfoLabels),
+		Metadata:     metric.Mode == metricsv1.ModeMetadata,
+	}
+	if err := xmetrics.ValidateInfoMappings(options.InfoMappings); err != nil {
+		return options, &reasonError{reason: metricsv1.ReasonInvalidSpec, error: fmt.Errorf("invalid info labels: %w", err)}
+	}
+	if metric.ResyncPeriod != nil {
+		options.ResyncPeriod = metric.ResyncPeriod.Duration
+	}
+	if metric.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(metric.Selector)
+		if err != nil {
+			return options, err
+		}
+		options.LabelSelector = selector.String()
+	}
+	if metric.FieldSelector != nil {
+		selector, err := xmetrics.ParseFieldSelector(*metric.FieldSelector)
+		if err != nil {
+			return options, err
+		}
+		options.FieldSelector = selector.String()
+	}
+	if metric.ConditionTypes != nil {
+		options.ConditionTypes = *metric.ConditionTypes
+	}
+	if metric.ReadyMessage != nil {
+		options.ReadyMessageLength = metric.ReadyMessage.MaxLength
+	}
+	if metric.ValueMetrics != nil {
+		for _, v := range *metric.ValueMetrics {
+			mapping := xmetrics.ValueMappings{
+				Name:      v.Name,
+				FieldPath: v.FieldPath,
+				Labels:    getInfoMappings(v.Labels),
+				Type:      string(v.Type),
+			}
+			if v.Help != nil {
+				mapping.Help = *v.Help
 			}
+			if v.Unit != nil {
+				mapping.Unit = *v.Unit
+			}
+			options.ValueMappings = append(options.ValueMappings, mapping)
+		}
+	}
+	if err := xmetrics.ValidateValueMappings(options.ValueMappings); err != nil {
+		return options, &reasonError{reason: metricsv1.ReasonInvalidSpec, error: fmt.Errorf("invalid value metrics: %w", err)}
+	}
+	return xmetrics.MergeStoreOptions(options), nil
+}
 
+func getInfoMappings(labels *[]metricsv1.InfoLabel) []xmetrics.InfoMappings {
+	var mappings []xmetrics.InfoMappings
+	if labels != nil {
+		for _, l := range *labels {
+			mappings = append(mappings, xmetrics.InfoMappings{
+				FieldPath: l.FieldPath,
+				Label:     l.Label,
+			})
 		}
 	}
+	return mappings
 }
 
 func (r *MetricReconciler) getResources(ctx context.Context, currentMetics *[]string, resouces *map[string]Resource) (map[string]Resource, []string, []string) {
@@ -376,7 +714,7 @@ func getSpecAndStatus(metric client.Object) (*metav1.ObjectMeta, *metricsv1.Metr
 	case *metricsv1.Metric:
 		return &t.ObjectMeta, &t.Spec, &t.Status, nil
 	case *metricsv1.ClusterMetric:
-		return &t.ObjectMeta, &t.Spec, &t.Status, nil
+		return &t.ObjectMeta, &t.Spec.MetricSpec, &t.Status, nil
 	default:
 		return nil, nil, nil, fmt.Errorf("not an metric type: %t", t)
 	}
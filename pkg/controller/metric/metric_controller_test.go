@@ -22,8 +22,11 @@ import (
 	"time"
 
 	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -274,7 +277,7 @@ var _ = Describe("Managed Metrics", func() {
 		})
 		It("Should select correct crds for single versions", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -308,14 +311,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).WithTimeout(time.Second * 20).Should(Equal(2))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testa_cloud_NameB_v1beta1"]
+			gvr2, ok := mmMap[metricNamespace+"_testa_cloud_NameB_v1beta1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameB v1beta1")
 
 			Expect(gvr2.Group).Should(Equal("testa.cloud"))
@@ -327,7 +330,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should select correct crd for multiple versions", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -363,14 +366,14 @@ var _ = Describe("Managed Metrics", func() {
 			// We expect only two entries as only crd versions with
 			// Storage: true will be watched
 
-			gvr1, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr1.Group).Should(Equal("testb.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("namecs"))
 
-			gvr2, ok := mmMap["testb_cloud_NameD_v2"]
+			gvr2, ok := mmMap[metricNamespace+"_testb_cloud_NameD_v2"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameD v2")
 
 			Expect(gvr2.Group).Should(Equal("testb.cloud"))
@@ -382,7 +385,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should exclude crds", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -417,14 +420,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(1))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			_, ok = mmMap["testa_cloud_NameB_v1beta1"]
+			_, ok = mmMap[metricNamespace+"_testa_cloud_NameB_v1beta1"]
 			Expect(ok).Should(BeFalse(), "Should not have metrics for NameB v1beta1")
 
 			Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
@@ -432,7 +435,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should include crds", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -467,21 +470,21 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(3))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testa_cloud_NameB_v1beta1"]
+			gvr2, ok := mmMap[metricNamespace+"_testa_cloud_NameB_v1beta1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameB v1beta1")
 
 			Expect(gvr2.Group).Should(Equal("testa.cloud"))
 			Expect(gvr2.Version).Should(Equal("v1beta1"))
 			Expect(gvr2.Resource).Should(Equal("namebs"))
 
-			gvr3, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr3, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr3.Group).Should(Equal("testb.cloud"))
@@ -492,7 +495,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should match categories", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -530,7 +533,7 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(1))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
@@ -542,7 +545,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should match categories default to AND", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -581,7 +584,7 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(1))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
@@ -593,7 +596,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should match categories OR", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -633,14 +636,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr2, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr2.Group).Should(Equal("testb.cloud"))
@@ -652,7 +655,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should prever matchNames", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -691,14 +694,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testa_cloud_NameB_v1beta1"]
+			gvr2, ok := mmMap[metricNamespace+"_testa_cloud_NameB_v1beta1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameB v1beta1")
 
 			Expect(gvr2.Group).Should(Equal("testa.cloud"))
@@ -710,7 +713,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should add finalizer", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -764,7 +767,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should remove metric on update", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -804,14 +807,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr2, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr2.Group).Should(Equal("testb.cloud"))
@@ -832,7 +835,7 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(1))
 
-			gvr4, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr4, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr4.Group).Should(Equal("testa.cloud"))
@@ -844,7 +847,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should remove all metric on update", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -884,14 +887,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr2, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr2.Group).Should(Equal("testb.cloud"))
@@ -917,7 +920,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should add only namespaces resources", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -952,14 +955,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr1, ok := mmMap["testc_cloud_NameE_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testc_cloud_NameE_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameE v1")
 
 			Expect(gvr1.Group).Should(Equal("testc.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("namees"))
 
-			gvr2, ok := mmMap["testc_cloud_NameG_v1"]
+			gvr2, ok := mmMap[metricNamespace+"_testc_cloud_NameG_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameG v1")
 
 			Expect(gvr2.Group).Should(Equal("testc.cloud"))
@@ -971,7 +974,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should add metrics only once", func(ctx SpecContext) {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metric2Name := "cmetricb"
@@ -1011,7 +1014,7 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(1))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
@@ -1046,14 +1049,14 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(2))
 
-			gvr2, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr2, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr2.Group).Should(Equal("testa.cloud"))
 			Expect(gvr2.Version).Should(Equal("v1"))
 			Expect(gvr2.Resource).Should(Equal("nameas"))
 
-			gvr3, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr3, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr3.Group).Should(Equal("testb.cloud"))
@@ -1072,7 +1075,7 @@ var _ = Describe("Managed Metrics", func() {
 
 		It("Should add count for objects", func() {
 			mm.ResetRegister()
-			metricsMemory = map[string]*MetricsMemory{}
+			metricsMemory.reset()
 
 			metricName := "cmetrica"
 			metricNamespace := generateNamespaceName()
@@ -1107,21 +1110,21 @@ var _ = Describe("Managed Metrics", func() {
 				return len(mmMap)
 			}).Should(Equal(3))
 
-			gvr1, ok := mmMap["testa_cloud_NameA_v1"]
+			gvr1, ok := mmMap[metricNamespace+"_testa_cloud_NameA_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameA v1")
 
 			Expect(gvr1.Group).Should(Equal("testa.cloud"))
 			Expect(gvr1.Version).Should(Equal("v1"))
 			Expect(gvr1.Resource).Should(Equal("nameas"))
 
-			gvr2, ok := mmMap["testa_cloud_NameB_v1beta1"]
+			gvr2, ok := mmMap[metricNamespace+"_testa_cloud_NameB_v1beta1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameB v1beta1")
 
 			Expect(gvr2.Group).Should(Equal("testa.cloud"))
 			Expect(gvr2.Version).Should(Equal("v1beta1"))
 			Expect(gvr2.Resource).Should(Equal("namebs"))
 
-			gvr3, ok := mmMap["testb_cloud_NameC_v1"]
+			gvr3, ok := mmMap[metricNamespace+"_testb_cloud_NameC_v1"]
 			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
 
 			Expect(gvr3.Group).Should(Equal("testb.cloud"))
@@ -1129,6 +1132,285 @@ var _ = Describe("Managed Metrics", func() {
 			Expect(gvr3.Resource).Should(Equal("namecs"))
 		})
 
+		It("Should pass info labels to the metric store", func(ctx SpecContext) {
+			mm.ResetRegister()
+			metricsMemory.reset()
+
+			metricName := "cmetrica"
+			metricNamespace := generateNamespaceName()
+
+			mNamespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metricNamespace,
+				},
+			}
+			err2 := k8sClient.Create(ctx, &mNamespace)
+			Expect(err2).NotTo(HaveOccurred(), "failed to create x-metrics namespace")
+			matchName := "namecs.testb.cloud"
+			metric := &metricsv1.Metric{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "metrics.crossplane.io/v1",
+					Kind:       "Metric",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metricName,
+					Namespace: metricNamespace,
+				},
+				Spec: metricsv1.MetricSpec{
+					MatchName: &matchName,
+					InfoLabels: &[]metricsv1.InfoLabel{
+						{
+							FieldPath: "spec.forProvider.region",
+							Label:     "region",
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, metric)).Should(Succeed())
+			Eventually(func() int {
+				return len(mm.GetRegister())
+			}).Should(Equal(1))
+
+			options, ok := mm.GetOptions()[metricNamespace+"_testb_cloud_NameC_v1"]
+			Expect(ok).Should(BeTrue(), "Should have metrics for NameC v1")
+			Expect(options.InfoMappings).Should(Equal([]xmetrics.InfoMappings{
+				{
+					FieldPath: "spec.forProvider.region",
+					Label:     "region",
+				},
+			}))
+
+			Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
+		}, SpecTimeout(time.Second*20))
+
+		It("Should use own metric stores for selectors", func(ctx SpecContext) {
+			mm.ResetRegister()
+			metricsMemory.reset()
+
+			metricName := "cmetrica"
+			metricNamespace := generateNamespaceName()
+
+			mNamespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metricNamespace,
+				},
+			}
+			err2 := k8sClient.Create(ctx, &mNamespace)
+			Expect(err2).NotTo(HaveOccurred(), "failed to create x-metrics namespace")
+			matchName := "namecs.testb.cloud"
+			metric := &metricsv1.Metric{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "metrics.crossplane.io/v1",
+					Kind:       "Metric",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metricName,
+					Namespace: metricNamespace,
+				},
+				Spec: metricsv1.MetricSpec{
+					MatchName: &matchName,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"team": "payments",
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, metric)).Should(Succeed())
+			Eventually(func() int {
+				return len(mm.GetRegister())
+			}).Should(Equal(1))
+
+			options, ok := mm.GetOptions()[metricNamespace+"_"+metric.GetName()+"_testb_cloud_NameC_v1"]
+			Expect(ok).Should(BeTrue(), "Should have prefixed metrics for NameC v1")
+			Expect(options.LabelSelector).Should(Equal("team=payments"))
+
+			Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
+		}, SpecTimeout(time.Second*20))
+
+		It("Should report conditions", func(ctx SpecContext) {
+			mm.ResetRegister()
+			metricsMemory.reset()
+
+			metricNamespace := generateNamespaceName()
+
+			mNamespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metricNamespace,
+				},
+			}
+			err2 := k8sClient.Create(ctx, &mNamespace)
+			Expect(err2).NotTo(HaveOccurred(), "failed to create x-metrics namespace")
+
+			for name, want := range map[string]struct {
+				matchName string
+				status    corev1.ConditionStatus
+				reason    xpv1.ConditionReason
+			}{
+				"valid":   {matchName: "testa.cloud", status: corev1.ConditionTrue, reason: metricsv1.ReasonWatching},
+				"invalid": {matchName: "testa.cloud(", status: corev1.ConditionFalse, reason: metricsv1.ReasonInvalidRegex},
+				"nomatch": {matchName: "nomatch.cloud", status: corev1.ConditionFalse, reason: metricsv1.ReasonNoMatchingCRDs},
+			} {
+				matchName := want.matchName
+				metric := &metricsv1.Metric{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "metrics.crossplane.io/v1",
+						Kind:       "Metric",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: metricNamespace,
+					},
+					Spec: metricsv1.MetricSpec{
+						MatchName: &matchName,
+					},
+				}
+				Expect(k8sClient.Create(ctx, metric)).Should(Succeed())
+
+				Eventually(func() xpv1.Condition {
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: metricNamespace}, metric)).Should(Succeed())
+					return metric.Status.GetCondition(xpv1.TypeReady)
+				}).Should(And(
+					HaveField("Status", want.status),
+					HaveField("Reason", want.reason),
+				))
+				Expect(metric.Status.ObservedGeneration).Should(Equal(metric.GetGeneration()))
+				if want.status == corev1.ConditionTrue {
+					Expect(metric.Status.MetricsPath).Should(HaveValue(Equal("/x-metrics/namespaces/" + metricNamespace + "/metrics/" + name)))
+					Expect(MetricStoresOf(metricNamespace, name)).ShouldNot(BeEmpty())
+				}
+
+				Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
+			}
+		}, SpecTimeout(time.Second*20))
+
+		It("Should report failing watches", func(ctx SpecContext) {
+			mm.ResetRegister()
+			metricsMemory.reset()
+
+			metricNamespace := generateNamespaceName()
+			mNamespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metricNamespace,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &mNamespace)).Should(Succeed())
+
+			matchName := "testa.cloud"
+			metric := &metricsv1.Metric{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "metrics.crossplane.io/v1",
+					Kind:       "Metric",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "watch",
+					Namespace: metricNamespace,
+				},
+				Spec: metricsv1.MetricSpec{
+					MatchName: &matchName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, metric)).Should(Succeed())
+			ready := func() xpv1.Condition {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "watch", Namespace: metricNamespace}, metric)).Should(Succeed())
+				return metric.Status.GetCondition(xpv1.TypeReady)
+			}
+			Eventually(ready).Should(HaveField("Reason", metricsv1.ReasonWatching))
+
+			stores := MetricStoresOf(metricNamespace, "watch")
+			Expect(stores).ShouldNot(BeEmpty())
+			gvr := mm.GetRegister()[stores[0]]
+
+			mm.SetWatchError(gvr, fmt.Errorf("objects is forbidden"))
+			Eventually(ready).Should(And(
+				HaveField("Status", corev1.ConditionFalse),
+				HaveField("Reason", metricsv1.ReasonWatchFailed),
+				HaveField("Message", ContainSubstring("objects is forbidden")),
+			))
+			Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues("Metric", string(metricsv1.ReasonWatchFailed)))).Should(BeNumerically(">", 0))
+
+			mm.SetWatchError(gvr, nil)
+			Eventually(ready).Should(And(
+				HaveField("Status", corev1.ConditionTrue),
+				HaveField("Reason", metricsv1.ReasonWatching),
+			))
+
+			Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
+		}, SpecTimeout(time.Second*20))
+
+		It("Should add metrics for crds installed later", func(ctx SpecContext) {
+			mm.ResetRegister()
+			metricsMemory.reset()
+
+			metricName := "cmetrica"
+			metricNamespace := generateNamespaceName()
+
+			mNamespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metricNamespace,
+				},
+			}
+			err2 := k8sClient.Create(ctx, &mNamespace)
+			Expect(err2).NotTo(HaveOccurred(), "failed to create x-metrics namespace")
+			matchName := "testd.cloud"
+			metric := &metricsv1.Metric{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "metrics.crossplane.io/v1",
+					Kind:       "Metric",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metricName,
+					Namespace: metricNamespace,
+				},
+				Spec: metricsv1.MetricSpec{
+					MatchName: &matchName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, metric)).Should(Succeed())
+
+			crd := apiextensions.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "namehs.testd.cloud",
+				},
+				Spec: apiextensions.CustomResourceDefinitionSpec{
+					Names: apiextensions.CustomResourceDefinitionNames{
+						Kind:   "NameH",
+						Plural: "namehs",
+					},
+					Group: "testd.cloud",
+					Scope: "Namespaced",
+					Versions: []apiextensions.CustomResourceDefinitionVersion{
+						{
+							Name:    "v1",
+							Served:  true,
+							Storage: true,
+							Schema: &apiextensions.CustomResourceValidation{
+								OpenAPIV3Schema: &apiextensions.JSONSchemaProps{
+									Type: "object",
+								},
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &crd)).Should(Succeed())
+
+			Eventually(func() bool {
+				_, ok := mm.GetRegister()[metricNamespace+"_testd_cloud_NameH_v1"]
+				return ok
+			}).WithTimeout(time.Second * 10).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, &crd)).Should(Succeed())
+			Eventually(func() bool {
+				_, ok := mm.GetRegister()[metricNamespace+"_testd_cloud_NameH_v1"]
+				return ok
+			}).WithTimeout(time.Second * 10).Should(BeFalse())
+
+			Expect(k8sClient.Delete(ctx, metric)).Should(Succeed())
+		}, SpecTimeout(time.Second*30))
+
 		It("Should delete crds correctly", func() {
 			ctx := context.Background()
 
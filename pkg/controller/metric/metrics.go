@@ -0,0 +1,42 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// outcomeDeleted is the outcome of reconciles removing the finalizer of a deleted metric object
+const outcomeDeleted = "Deleted"
+
+// reconcileOutcomes counts the reconciles by the reason of the Ready condition they set.
+// Failed reconciles are reported in the conditions and requeued without an error,
+// so the reconcile metrics of controller-runtime count them as successful.
+var reconcileOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "x_metric_reconcile_total",
+	Help: "Number of reconciles of the metric objects of a kind by outcome, the reason of their Ready condition",
+}, []string{"kind", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(reconcileOutcomes)
+}
+
+func (r *MetricReconciler) recordOutcome(outcome xpv1.ConditionReason) {
+	reconcileOutcomes.WithLabelValues(r.Kind, string(outcome)).Inc()
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// metricsRegistry keeps track of the metric stores and their consumers.
+// It is shared by the Metric and ClusterMetric reconcilers, every method is safe for concurrent use.
+// The mutex guards the memory only, it is not held while the handler is called,
+// so reconciles of different metric stores start and stop their informers in parallel.
+type metricsRegistry struct {
+	mutex  sync.Mutex
+	memory map[string]*MetricsMemory
+	// locks serializes the changes of each metric store
+	locks map[string]*storeLock
+	// reconciled holds the consumers reconciled at least once
+	reconciled map[string]struct{}
+}
+
+type storeLock struct {
+	sync.Mutex
+	// users is the number of callers holding or waiting for the lock
+	users int
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		memory:     map[string]*MetricsMemory{},
+		locks:      map[string]*storeLock{},
+		reconciled: map[string]struct{}{},
+	}
+}
+
+// lockStore locks the metric store against changes by other consumers and returns the func unlocking it.
+// Only the holder of the lock of a store replaces or removes its memory.
+func (m *metricsRegistry) lockStore(metricName string) func() {
+	m.mutex.Lock()
+	lock, ok := m.locks[metricName]
+	if !ok {
+		lock = &storeLock{}
+		m.locks[metricName] = lock
+	}
+	lock.users++
+	m.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(m.locks, metricName)
+		}
+	}
+}
+
+// reset forgets all metric stores without removing them from the handler
+func (m *metricsRegistry) reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.memory = map[string]*MetricsMemory{}
+	m.reconciled = map[string]struct{}{}
+}
+
+// markReconciled records that a reconcile of the consumer completed, whatever its outcome
+func (m *metricsRegistry) markReconciled(consumer string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.reconciled[consumer] = struct{}{}
+}
+
+// wasReconciled returns true, if a reconcile of the consumer completed
+func (m *metricsRegistry) wasReconciled(consumer string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, ok := m.reconciled[consumer]
+	return ok
+}
+
+// get returns a copy of the memory of the metric store
+func (m *metricsRegistry) get(metricName string) (MetricsMemory, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	memory, ok := m.memory[metricName]
+	if !ok {
+		return MetricsMemory{}, false
+	}
+	copied := *memory
+	copied.Consumer = make(map[string]xmetrics.StoreOptions, len(memory.Consumer))
+	for k, v := range memory.Consumer {
+		copied.Consumer[k] = v
+	}
+	return copied, true
+}
+
+// currentMetrics returns the names of all metric stores the consumer is part of
+func (m *metricsRegistry) currentMetrics(consumer string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	currentMetrics := []string{}
+	for metricName, metric := range m.memory {
+		if _, ok := metric.Consumer[consumer]; ok {
+			currentMetrics = append(currentMetrics, metricName)
+		}
+	}
+	sort.Strings(currentMetrics)
+	return currentMetrics
+}
+
+// storeNames returns the sorted names of all registered metric stores
+func (m *metricsRegistry) storeNames() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, 0, len(m.memory))
+	for metricName := range m.memory {
+		names = append(names, metricName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// consumersOf returns the consumers of all metric stores of the gvr
+func (m *metricsRegistry) consumersOf(gvr schema.GroupVersionResource) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	consumers := []string{}
+	seen := map[string]struct{}{}
+	for _, metric := range m.memory {
+		if metric.GVR != gvr {
+			continue
+		}
+		for consumer := range metric.Consumer {
+			if _, ok := seen[consumer]; !ok {
+				seen[consumer] = struct{}{}
+				consumers = append(consumers, consumer)
+			}
+		}
+	}
+	sort.Strings(consumers)
+	return consumers
+}
+
+// join adds the consumer to the metric store, the store is registered if it does not exist yet
+func (m *metricsRegistry) join(ctx context.Context, handler xmetrics.IManagedMetricsHandler, metricName string, gvr schema.GroupVersionResource, namespace string, consumer string, opts xmetrics.StoreOptions) {
+	unlock := m.lockStore(metricName)
+	defer unlock()
+
+	m.mutex.Lock()
+	memory, ok := m.memory[metricName]
+	if ok {
+		memory.Consumer[consumer] = opts
+	}
+	m.mutex.Unlock()
+	if ok {
+		m.refresh(ctx, handler, metricName)
+		return
+	}
+
+	channel := handler.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace, opts)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.memory[metricName] = &MetricsMemory{
+		Consumer: map[string]xmetrics.StoreOptions{
+			consumer: opts,
+		},
+		Channel: &CloseChannel{
+			Channel: channel,
+			Closed:  false,
+		},
+		GVR:       gvr,
+		Namespace: namespace,
+		Options:   opts,
+	}
+}
+
+// update sets the options of the consumer and moves the metric store to the gvr, if the version changed.
+// It returns true, if the metric store was migrated.
+func (m *metricsRegistry) update(ctx context.Context, handler xmetrics.IManagedMetricsHandler, metricName string, gvr schema.GroupVersionResource, consumer string, opts xmetrics.StoreOptions) bool {
+	unlock := m.lockStore(metricName)
+	defer unlock()
+
+	m.mutex.Lock()
+	memory, ok := m.memory[metricName]
+	if !ok || memory.Channel.Closed {
+		m.mutex.Unlock()
+		return false
+	}
+	migrated := memory.GVR != gvr
+	m.mutex.Unlock()
+
+	if migrated {
+		m.migrate(ctx, handler, metricName, gvr)
+	}
+	m.mutex.Lock()
+	memory.Consumer[consumer] = opts
+	m.mutex.Unlock()
+	m.refresh(ctx, handler, metricName)
+	return migrated
+}
+
+// cleanup removes the consumer from the metric stores, stores without consumers are stopped and removed
+func (m *metricsRegistry) cleanup(handler xmetrics.IManagedMetricsHandler, metrics []string, consumer string) {
+	for _, metricName := range metrics {
+		m.leave(handler, metricName, consumer)
+	}
+}
+
+// leave removes the consumer from the metric store, the store is stopped and removed after its last consumer left
+func (m *metricsRegistry) leave(handler xmetrics.IManagedMetricsHandler, metricName string, consumer string) {
+	unlock := m.lockStore(metricName)
+	defer unlock()
+
+	m.mutex.Lock()
+	metric, ok := m.memory[metricName]
+	if !ok || metric.Channel.Closed {
+		m.mutex.Unlock()
+		return
+	}
+	delete(metric.Consumer, consumer)
+	if len(metric.Consumer) > 0 {
+		m.mutex.Unlock()
+		return
+	}
+	metric.Channel.Closed = true
+	delete(m.memory, metricName)
+	m.mutex.Unlock()
+
+	close(metric.Channel.Channel)
+	handler.RemoveMetricStore(metricName)
+}
+
+// refresh registers the metric store again, if the merged options of
+// all consumers differ from the options the store was registered with.
+// If only the selected namespaces differ, they are updated in the registered store,
+// which keeps the series of the namespaces still selected without listing the objects again.
+// The caller has to hold the lock of the store.
+func (m *metricsRegistry) refresh(ctx context.Context, handler xmetrics.IManagedMetricsHandler, metricName string) {
+	m.mutex.Lock()
+	memory, ok := m.memory[metricName]
+	if !ok || memory.Channel.Closed {
+		m.mutex.Unlock()
+		return
+	}
+	options := mergeConsumerOptions(memory.Consumer)
+	if reflect.DeepEqual(options, memory.Options) {
+		m.mutex.Unlock()
+		return
+	}
+	previous, gvr, namespace := memory.Channel, memory.GVR, memory.Namespace
+	namespacesOnly := namespacesChanged(memory.Options, options)
+	m.mutex.Unlock()
+
+	if namespacesOnly && handler.UpdateNamespaces(metricName, options.Namespaces) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		memory.Options = options
+		return
+	}
+	close(previous.Channel)
+	handler.RemoveMetricStore(metricName)
+	channel := handler.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace, options)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	memory.Channel = &CloseChannel{
+		Channel: channel,
+		Closed:  false,
+	}
+	memory.Options = options
+}
+
+// namespacesChanged returns true, if the options of a store selecting namespaces differ in the namespaces only
+func namespacesChanged(registered xmetrics.StoreOptions, options xmetrics.StoreOptions) bool {
+	registered.Namespaces, options.Namespaces = nil, nil
+	return registered.NamespaceFilter && reflect.DeepEqual(registered, options)
+}
+
+// migrate moves the metric store to another version of its resource,
+// e.g. after the storage version of a crd changed.
+// The caller has to hold the lock of the store.
+func (m *metricsRegistry) migrate(ctx context.Context, handler xmetrics.IManagedMetricsHandler, metricName string, gvr schema.GroupVersionResource) {
+	m.mutex.Lock()
+	memory := m.memory[metricName]
+	previous, namespace, options := memory.Channel, memory.Namespace, memory.Options
+	m.mutex.Unlock()
+
+	close(previous.Channel)
+	channel := handler.MigrateMetricStore(ctx, metricName, gvr, namespace, options)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	memory.Channel = &CloseChannel{
+		Channel: channel,
+		Closed:  false,
+	}
+	memory.GVR = gvr
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	mock "github.com/crossplane-contrib/x-metrics/pkg/controller/metric/mock"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+func TestMetricsRegistryShare(t *testing.T) {
+	g := NewWithT(t)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	registry := newMetricsRegistry()
+
+	registry.join(context.TODO(), &handlerMock, "objects", gvr, "", "a", xmetrics.StoreOptions{})
+	registry.join(context.TODO(), &handlerMock, "objects", gvr, "", "b", xmetrics.StoreOptions{})
+	g.Expect(handlerMock.GetNumOfCalls()["objects"]).Should(Equal(1))
+	g.Expect(registry.currentMetrics("a")).Should(Equal([]string{"objects"}))
+
+	registry.cleanup(&handlerMock, []string{"objects"}, "a")
+	g.Expect(handlerMock.GetRegister()).Should(HaveKey("objects"))
+	registry.cleanup(&handlerMock, []string{"objects"}, "b")
+	g.Expect(handlerMock.GetRegister()).ShouldNot(HaveKey("objects"))
+	_, ok := registry.get("objects")
+	g.Expect(ok).Should(BeFalse())
+}
+
+func TestMetricsRegistryUpdateNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	registry := newMetricsRegistry()
+
+	registry.join(context.TODO(), &handlerMock, "objects", gvr, "", "a", xmetrics.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns1", "ns2"}})
+	registry.update(context.TODO(), &handlerMock, "objects", gvr, "a", xmetrics.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns1", "ns3"}})
+	g.Expect(handlerMock.GetNumOfCalls()["objects"]).Should(Equal(1))
+	g.Expect(handlerMock.GetOptions()["objects"].Namespaces).Should(Equal([]string{"ns1", "ns3"}))
+	memory, _ := registry.get("objects")
+	g.Expect(memory.Options.Namespaces).Should(Equal([]string{"ns1", "ns3"}))
+
+	// other changes register the store again
+	registry.update(context.TODO(), &handlerMock, "objects", gvr, "a", xmetrics.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns1"}, LabelSelector: "team=payments"})
+	g.Expect(handlerMock.GetOptions()["objects"]).Should(Equal(xmetrics.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns1"}, LabelSelector: "team=payments"}))
+}
+
+func TestMetricsRegistryMigrate(t *testing.T) {
+	g := NewWithT(t)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	registry := newMetricsRegistry()
+
+	registry.join(context.TODO(), &handlerMock, "objects", gvr, "", "a", xmetrics.StoreOptions{})
+	v2 := gvr
+	v2.Version = "v2"
+	g.Expect(registry.update(context.TODO(), &handlerMock, "objects", v2, "a", xmetrics.StoreOptions{})).Should(BeTrue())
+	g.Expect(registry.update(context.TODO(), &handlerMock, "objects", v2, "a", xmetrics.StoreOptions{})).Should(BeFalse())
+
+	memory, ok := registry.get("objects")
+	g.Expect(ok).Should(BeTrue())
+	g.Expect(memory.GVR).Should(Equal(v2))
+	g.Expect(handlerMock.GetMigrations()["objects"]).Should(Equal(1))
+}
+
+func TestMetricsRegistryConcurrency(t *testing.T) {
+	g := NewWithT(t)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	registry := newMetricsRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			consumer := fmt.Sprintf("consumer%d", i)
+			for j := 0; j < 50; j++ {
+				metrics := []string{"shared", consumer}
+				for _, metricName := range metrics {
+					registry.join(context.TODO(), &handlerMock, metricName, gvr, "", consumer, xmetrics.StoreOptions{
+						InfoMappings: []xmetrics.InfoMappings{{FieldPath: "spec.a", Label: consumer}},
+					})
+					registry.update(context.TODO(), &handlerMock, metricName, gvr, consumer, xmetrics.StoreOptions{})
+				}
+				registry.currentMetrics(consumer)
+				registry.cleanup(&handlerMock, metrics, consumer)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	g.Expect(handlerMock.GetRegister()).Should(BeEmpty())
+	for i := 0; i < 8; i++ {
+		g.Expect(registry.currentMetrics(fmt.Sprintf("consumer%d", i))).Should(BeEmpty())
+	}
+}
+
+// blockingHandler blocks registering the metric store of a name until it is released
+type blockingHandler struct {
+	*mock.ManagedMetricsHandlerMock
+	metricName string
+	release    chan struct{}
+}
+
+func (h *blockingHandler) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts xmetrics.StoreOptions) chan struct{} {
+	if metricName == h.metricName {
+		<-h.release
+	}
+	return h.ManagedMetricsHandlerMock.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace, opts)
+}
+
+func TestMetricsRegistryLocking(t *testing.T) {
+	g := NewWithT(t)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	blocking := &blockingHandler{ManagedMetricsHandlerMock: &handlerMock, metricName: "slow", release: make(chan struct{})}
+	registry := newMetricsRegistry()
+
+	joined := make(chan struct{})
+	go func() {
+		defer close(joined)
+		registry.join(context.TODO(), blocking, "slow", gvr, "", "a", xmetrics.StoreOptions{})
+	}()
+	g.Eventually(func() bool {
+		registry.mutex.Lock()
+		defer registry.mutex.Unlock()
+		return registry.locks["slow"] != nil
+	}).Should(BeTrue())
+
+	registry.join(context.TODO(), blocking, "fast", gvr, "", "b", xmetrics.StoreOptions{})
+	g.Expect(registry.currentMetrics("b")).Should(Equal([]string{"fast"}))
+	g.Expect(registry.consumersOf(gvr)).Should(Equal([]string{"b"}))
+	g.Consistently(joined).ShouldNot(BeClosed())
+
+	close(blocking.release)
+	g.Eventually(joined).Should(BeClosed())
+	g.Expect(registry.currentMetrics("a")).Should(Equal([]string{"slow"}))
+	g.Expect(handlerMock.GetNumOfCalls()["slow"]).Should(Equal(1))
+
+	registry.cleanup(blocking, []string{"slow", "fast"}, "a")
+	registry.cleanup(blocking, []string{"fast"}, "b")
+	g.Expect(handlerMock.GetRegister()).Should(BeEmpty())
+	g.Expect(registry.locks).Should(BeEmpty())
+}
+
+func TestStorePrefix(t *testing.T) {
+	g := NewWithT(t)
+
+	payments := xmetrics.StoreOptions{LabelSelector: "team=payments"}
+
+	g.Expect(storePrefix("", "m", xmetrics.StoreOptions{}, "")).Should(BeEmpty())
+	g.Expect(storePrefix("a", "m", xmetrics.StoreOptions{}, "")).Should(Equal("a"))
+	g.Expect(storePrefix("a", "m", payments, "")).Should(Equal("a_m"))
+	g.Expect(storePrefix("a", "m", xmetrics.StoreOptions{LabelSelector: "team=billing"}, "")).Should(Equal("a_m"))
+	g.Expect(storePrefix("a", "m", xmetrics.StoreOptions{FieldSelector: "metadata.name=x"}, "")).Should(Equal("a_m"))
+	g.Expect(storePrefix("", "m", xmetrics.StoreOptions{}, "env=prod")).Should(Equal("m"))
+}
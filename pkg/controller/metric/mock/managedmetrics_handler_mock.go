@@ -19,23 +19,39 @@ package xmetrics
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
 )
 
 type ManagedMetricsHandlerMock struct {
+	mutex         *sync.Mutex
 	register      map[string]schema.GroupVersionResource
+	options       map[string]xmetrics.StoreOptions
 	multipleCalls map[string]int
+	migrations    map[string]int
+	watchErrors   map[schema.GroupVersionResource]error
+	unsynced      map[string][]schema.GroupVersionResource
+	notify        []func(schema.GroupVersionResource)
 }
 
 func NewManagedMetricsHandlerMock() ManagedMetricsHandlerMock {
 	return ManagedMetricsHandlerMock{
+		mutex:         &sync.Mutex{},
 		register:      map[string]schema.GroupVersionResource{},
+		options:       map[string]xmetrics.StoreOptions{},
 		multipleCalls: map[string]int{},
+		migrations:    map[string]int{},
+		watchErrors:   map[schema.GroupVersionResource]error{},
+		unsynced:      map[string][]schema.GroupVersionResource{},
 	}
 }
 
 func (m *ManagedMetricsHandlerMock) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	for k := range m.register {
 		_, err := writer.Write([]byte(k + ";"))
 		if err != nil {
@@ -45,28 +61,129 @@ func (m *ManagedMetricsHandlerMock) ServeHTTP(writer http.ResponseWriter, r *htt
 	}
 }
 
-func (m *ManagedMetricsHandlerMock) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) chan struct{} {
+func (m *ManagedMetricsHandlerMock) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts xmetrics.StoreOptions) chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if _, ok := m.register[metricName]; ok {
 		m.multipleCalls[metricName] = m.multipleCalls[metricName] + 1
 	} else {
 		m.multipleCalls[metricName] = 1
 	}
 	m.register[metricName] = gvr
+	m.options[metricName] = opts
+	return make(chan struct{})
+}
+
+func (m *ManagedMetricsHandlerMock) MigrateMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts xmetrics.StoreOptions) chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.migrations[metricName] = m.migrations[metricName] + 1
+	m.register[metricName] = gvr
+	m.options[metricName] = opts
 	return make(chan struct{})
 }
 
+func (m *ManagedMetricsHandlerMock) GetMigrations() map[string]int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return copyMap(m.migrations)
+}
+
 func (m *ManagedMetricsHandlerMock) GetRegister() map[string]schema.GroupVersionResource {
-	return m.register
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return copyMap(m.register)
+}
+
+func (m *ManagedMetricsHandlerMock) GetOptions() map[string]xmetrics.StoreOptions {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return copyMap(m.options)
 }
 
 func (m *ManagedMetricsHandlerMock) GetNumOfCalls() map[string]int {
-	return m.multipleCalls
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return copyMap(m.multipleCalls)
 }
 
 func (m *ManagedMetricsHandlerMock) ResetRegister() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.register = map[string]schema.GroupVersionResource{}
+	m.options = map[string]xmetrics.StoreOptions{}
 	m.multipleCalls = map[string]int{}
+	m.migrations = map[string]int{}
 }
+
 func (m *ManagedMetricsHandlerMock) RemoveMetricStore(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	delete(m.register, name)
+	delete(m.options, name)
+}
+
+func (m *ManagedMetricsHandlerMock) WatchError(gvr schema.GroupVersionResource) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.watchErrors[gvr]
+}
+
+func (m *ManagedMetricsHandlerMock) NotifyWatchState(notify func(gvr schema.GroupVersionResource)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.notify = append(m.notify, notify)
+}
+
+// SetWatchError sets the error of the watch of the resource and notifies the watch state funcs
+func (m *ManagedMetricsHandlerMock) SetWatchError(gvr schema.GroupVersionResource, err error) {
+	m.mutex.Lock()
+	if err == nil {
+		delete(m.watchErrors, gvr)
+	} else {
+		m.watchErrors[gvr] = err
+	}
+	notify := append([]func(schema.GroupVersionResource){}, m.notify...)
+	m.mutex.Unlock()
+	for _, n := range notify {
+		n(gvr)
+	}
+}
+
+func (m *ManagedMetricsHandlerMock) UnsyncedResources(metricName string) []schema.GroupVersionResource {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.unsynced[metricName]
+}
+
+// SetUnsynced sets the resources of the metric store, which did not complete their initial list yet
+func (m *ManagedMetricsHandlerMock) SetUnsynced(metricName string, gvrs ...schema.GroupVersionResource) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(gvrs) == 0 {
+		delete(m.unsynced, metricName)
+		return
+	}
+	m.unsynced[metricName] = gvrs
+}
+
+// UpdateNamespaces sets the namespaces in the options of a registered metric store selecting namespaces
+func (m *ManagedMetricsHandlerMock) UpdateNamespaces(metricName string, namespaces []string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	opts, ok := m.options[metricName]
+	if !ok || !opts.NamespaceFilter {
+		return false
+	}
+	opts.Namespaces = namespaces
+	m.options[metricName] = opts
+	return true
+}
+
+func copyMap[K comparable, V any](in map[K]V) map[K]V {
+	out := make(map[K]V, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
 }
@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// ReadyGate is the ready check of the manager. The pod is ready once the Metrics and ClusterMetrics existing at startup
+// were reconciled and every registered metric store completed the initial list of its resources, scrapes before would miss objects.
+// Stores registered later on, e.g. for a new metric object or crd, make the pod unready until they synced, too.
+// Resources failing to list keep their stores unsynced, their errors are reported with the stores.
+type ReadyGate struct {
+	client   client.Reader
+	handler  xmetrics.IManagedMetricsHandler
+	registry *metricsRegistry
+
+	mutex sync.Mutex
+	// pending holds the consumers existing at startup, which were not reconciled yet. It is nil until they were listed.
+	pending map[string]struct{}
+}
+
+// NewReadyGate returns the gate of the metric objects listed with the client and the metric stores of the handler
+func NewReadyGate(c client.Reader, handler xmetrics.IManagedMetricsHandler) *ReadyGate {
+	return &ReadyGate{
+		client:   c,
+		handler:  handler,
+		registry: metricsMemory,
+	}
+}
+
+// Check implements a healthz.Checker. The error lists the metric objects not reconciled yet
+// and the unsynced stores with their resources, it is served on the endpoint of the check, e.g. /readyz/stores.
+func (g *ReadyGate) Check(r *http.Request) error {
+	if err := g.checkReconciled(r); err != nil {
+		return err
+	}
+	var unsynced []string
+	for _, metricName := range g.registry.storeNames() {
+		var resources []string
+		for _, gvr := range g.handler.UnsyncedResources(metricName) {
+			resource := gvr.String()
+			if err := g.handler.WatchError(gvr); err != nil {
+				resource += ": " + err.Error()
+			}
+			resources = append(resources, resource)
+		}
+		if len(resources) > 0 {
+			sort.Strings(resources)
+			unsynced = append(unsynced, fmt.Sprintf("%s (%s)", metricName, strings.Join(resources, ", ")))
+		}
+	}
+	if len(unsynced) > 0 {
+		return fmt.Errorf("metric stores not synced: %s", strings.Join(unsynced, "; "))
+	}
+	return nil
+}
+
+// checkReconciled returns an error listing the metric objects existing at startup, which were not reconciled yet.
+// Their stores are not registered before, so the stores of the registry cannot be waited for.
+func (g *ReadyGate) checkReconciled(r *http.Request) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.pending == nil {
+		pending, err := g.listConsumers(r)
+		if err != nil {
+			return err
+		}
+		g.pending = pending
+	}
+	var unreconciled []string
+	for consumer := range g.pending {
+		if !g.registry.wasReconciled(consumer) {
+			unreconciled = append(unreconciled, consumer)
+			continue
+		}
+		delete(g.pending, consumer)
+	}
+	if len(unreconciled) > 0 {
+		sort.Strings(unreconciled)
+		return fmt.Errorf("metric objects not reconciled: %s", strings.Join(unreconciled, ", "))
+	}
+	return nil
+}
+
+// listConsumers returns the consumer names of all Metrics and ClusterMetrics
+func (g *ReadyGate) listConsumers(r *http.Request) (map[string]struct{}, error) {
+	ctx := r.Context()
+	consumers := map[string]struct{}{}
+	metrics := &metricsv1.MetricList{}
+	if err := g.client.List(ctx, metrics); err != nil {
+		return nil, err
+	}
+	for _, m := range metrics.Items {
+		consumers[consumerName(m.Namespace, m.Name)] = struct{}{}
+	}
+	clusterMetrics := &metricsv1.ClusterMetricList{}
+	if err := g.client.List(ctx, clusterMetrics); err != nil {
+		return nil, err
+	}
+	for _, m := range clusterMetrics.Items {
+		consumers[consumerName("", m.Name)] = struct{}{}
+	}
+	return consumers, nil
+}
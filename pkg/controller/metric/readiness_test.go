@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+	mock "github.com/crossplane-contrib/x-metrics/pkg/controller/metric/mock"
+	xmetrics "github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+// newTestGate returns a gate of a Metric and a ClusterMetric existing at startup with its own registry
+func newTestGate(g *WithT) (*mock.ManagedMetricsHandlerMock, *metricsRegistry, *ReadyGate) {
+	scheme := runtime.NewScheme()
+	g.Expect(metricsv1.AddToScheme(scheme)).Should(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&metricsv1.Metric{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "a"}},
+		&metricsv1.ClusterMetric{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+	).Build()
+	handlerMock := mock.NewManagedMetricsHandlerMock()
+	registry := newMetricsRegistry()
+	gate := NewReadyGate(c, &handlerMock)
+	gate.registry = registry
+	return &handlerMock, registry, gate
+}
+
+// probe runs the check of the gate like a request to /readyz/stores
+func probe(gate *ReadyGate) error {
+	return gate.Check(httptest.NewRequest(http.MethodGet, "/readyz/stores", nil))
+}
+
+func TestReadyGateReconciled(t *testing.T) {
+	g := NewWithT(t)
+	_, registry, gate := newTestGate(g)
+
+	g.Expect(probe(gate)).Should(MatchError("metric objects not reconciled: b, ns1::a"))
+
+	registry.markReconciled("ns1::a")
+	g.Expect(probe(gate)).Should(MatchError("metric objects not reconciled: b"))
+
+	registry.markReconciled("b")
+	g.Expect(probe(gate)).Should(Succeed())
+}
+
+func TestReadyGateUnsyncedStores(t *testing.T) {
+	g := NewWithT(t)
+	handlerMock, registry, gate := newTestGate(g)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	registry.join(context.TODO(), handlerMock, "objects", gvr, "", "ns1::a", xmetrics.StoreOptions{})
+	registry.join(context.TODO(), handlerMock, "objects", gvr, "", "b", xmetrics.StoreOptions{})
+	// the store of a metric object created after startup
+	registry.join(context.TODO(), handlerMock, "later_objects", gvr, "", "c", xmetrics.StoreOptions{})
+	registry.markReconciled("ns1::a")
+	registry.markReconciled("b")
+	handlerMock.SetUnsynced("objects", gvr)
+	handlerMock.SetUnsynced("later_objects", gvr)
+
+	g.Expect(probe(gate)).Should(MatchError("metric stores not synced: later_objects (test.cloud/v1, Resource=objects); objects (test.cloud/v1, Resource=objects)"))
+
+	handlerMock.SetUnsynced("objects")
+	g.Expect(probe(gate)).Should(MatchError("metric stores not synced: later_objects (test.cloud/v1, Resource=objects)"))
+
+	handlerMock.SetUnsynced("later_objects")
+	g.Expect(probe(gate)).Should(Succeed())
+}
+
+func TestReadyGateWatchErrors(t *testing.T) {
+	g := NewWithT(t)
+	handlerMock, registry, gate := newTestGate(g)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	registry.join(context.TODO(), handlerMock, "objects", gvr, "", "ns1::a", xmetrics.StoreOptions{})
+	registry.markReconciled("ns1::a")
+	registry.markReconciled("b")
+	handlerMock.SetUnsynced("objects", gvr)
+	handlerMock.SetWatchError(gvr, errors.New("forbidden"))
+
+	g.Expect(probe(gate)).Should(MatchError("metric stores not synced: objects (test.cloud/v1, Resource=objects: forbidden)"))
+}
+
+func TestReadyGateLaterStores(t *testing.T) {
+	g := NewWithT(t)
+	handlerMock, registry, gate := newTestGate(g)
+	gvr := schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}
+
+	registry.markReconciled("ns1::a")
+	registry.markReconciled("b")
+	g.Expect(probe(gate)).Should(Succeed())
+
+	registry.join(context.TODO(), handlerMock, "objects", gvr, "", "ns1::a", xmetrics.StoreOptions{})
+	handlerMock.SetUnsynced("objects", gvr)
+	g.Expect(probe(gate)).Should(MatchError(ContainSubstring("metric stores not synced: objects")))
+
+	handlerMock.SetUnsynced("objects")
+	g.Expect(probe(gate)).Should(Succeed())
+}
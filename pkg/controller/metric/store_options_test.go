@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+)
+
+// reasonOf returns the reason of the error reported in the Ready condition of a metric
+func reasonOf(err error) string {
+	var rErr *reasonError
+	if errors.As(err, &rErr) {
+		return string(rErr.reason)
+	}
+	return ""
+}
+
+func TestGetStoreOptionsInfoLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := getStoreOptions(&metricsv1.MetricSpec{InfoLabels: &[]metricsv1.InfoLabel{{FieldPath: "spec.region", Label: "region"}}})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	for _, labels := range [][]metricsv1.InfoLabel{
+		{{FieldPath: "metadata.name", Label: "name"}},
+		{{FieldPath: "spec.namespace", Label: "namespace"}},
+		{{FieldPath: "spec.region", Label: "region"}, {FieldPath: "spec.zone", Label: "region"}},
+	} {
+		labels := labels
+		_, err := getStoreOptions(&metricsv1.MetricSpec{InfoLabels: &labels})
+		g.Expect(err).Should(MatchError(ContainSubstring("invalid info labels")))
+		g.Expect(reasonOf(err)).Should(Equal(string(metricsv1.ReasonInvalidSpec)))
+	}
+}
+
+func TestGetStoreOptionsValueMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := getStoreOptions(&metricsv1.MetricSpec{ValueMetrics: &[]metricsv1.ValueMetric{
+		{Name: "nodes", FieldPath: "spec.nodes", Labels: &[]metricsv1.InfoLabel{{FieldPath: "spec.region", Label: "region"}}},
+	}})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	for _, metrics := range [][]metricsv1.ValueMetric{
+		{{Name: "ready", FieldPath: "spec.ready"}},
+		{{Name: "resource_count", FieldPath: "spec.count"}},
+		{{Name: "nodes", FieldPath: "spec.nodes"}, {Name: "nodes", FieldPath: "status.nodes"}},
+		{{Name: "nodes", FieldPath: "spec.nodes", Labels: &[]metricsv1.InfoLabel{{FieldPath: "metadata.name", Label: "name"}}}},
+		{{Name: "nodes", FieldPath: "spec.nodes", Labels: &[]metricsv1.InfoLabel{{FieldPath: "spec.a", Label: "a"}, {FieldPath: "spec.b", Label: "a"}}}},
+	} {
+		metrics := metrics
+		_, err := getStoreOptions(&metricsv1.MetricSpec{ValueMetrics: &metrics})
+		g.Expect(err).Should(MatchError(ContainSubstring("invalid value metrics")))
+		g.Expect(reasonOf(err)).Should(Equal(string(metricsv1.ReasonInvalidSpec)))
+	}
+}
@@ -87,14 +87,17 @@ var _ = BeforeSuite(func() {
 		Client:    k8sManager.GetClient(),
 		Scheme:    k8sManager.GetScheme(),
 		MmHandler: &mm,
+		// metrics are reconciled in parallel to cover the shared controller state
+		MaxConcurrentReconciles: 2,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&MetricReconciler{
-		Kind:      "ClusterMetric",
-		Client:    k8sManager.GetClient(),
-		Scheme:    k8sManager.GetScheme(),
-		MmHandler: &mm,
+		Kind:                    "ClusterMetric",
+		Client:                  k8sManager.GetClient(),
+		Scheme:                  k8sManager.GetScheme(),
+		MmHandler:               &mm,
+		MaxConcurrentReconciles: 2,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
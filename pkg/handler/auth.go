@@ -0,0 +1,237 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metricsv1 "github.com/crossplane-contrib/x-metrics/api/v1"
+)
+
+// accessCacheTTL is the time the decisions of SubjectAccessReviews are cached for
+const accessCacheTTL = time.Minute
+
+// AuthHandler serves the metrics to authenticated and authorized users only.
+// The bearer token of a request is authenticated with a TokenReview and the access of the user is checked with SubjectAccessReviews:
+//   - the path of a Metric needs get on the Metric
+//   - the path of a ClusterMetric needs get on the ClusterMetric
+//
+// The metrics are scoped to the reader, only objects in namespaces the user can list the resources of are written.
+// Cluster scoped objects need list access in all namespaces.
+type AuthHandler struct {
+	client    kubernetes.Interface
+	next      http.Handler
+	audiences []string
+
+	mutex  sync.Mutex
+	access map[accessKey]accessDecision
+}
+
+type accessKey struct {
+	user       string
+	attributes authorizationv1.ResourceAttributes
+}
+
+type accessDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+// NewAuthHandler returns a handler authenticating and authorizing the requests with the client,
+// before they are passed to next. Tokens are reviewed for the audiences, the audience of the api server is used if empty.
+func NewAuthHandler(client kubernetes.Interface, next http.Handler, audiences ...string) *AuthHandler {
+	return &AuthHandler{
+		client:    client,
+		next:      next,
+		audiences: audiences,
+		access:    map[accessKey]accessDecision{},
+	}
+}
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+func (a *AuthHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	logger := log.FromContext(r.Context())
+
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(err, "unable to review token")
+		http.Error(writer, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !review.Status.Authenticated {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attributes, ok := requiredAccess(r)
+	if !ok {
+		http.NotFound(writer, r)
+		return
+	}
+	rd := &reader{auth: a, user: review.Status.User}
+	for _, required := range attributes {
+		allowed, err := rd.allowed(r.Context(), required)
+		if err != nil {
+			logger.Error(err, "unable to review access")
+			http.Error(writer, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(writer, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+	a.next.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), readerKey{}, rd)))
+}
+
+// reader is an authenticated user reading metrics
+type reader struct {
+	auth *AuthHandler
+	user authenticationv1.UserInfo
+}
+
+type readerKey struct{}
+
+// readerFromContext returns the reader authenticated by the AuthHandler
+func readerFromContext(ctx context.Context) (*reader, bool) {
+	rd, ok := ctx.Value(readerKey{}).(*reader)
+	return rd, ok
+}
+
+// allowed returns true, if the reader has the access, decisions are cached for accessCacheTTL
+func (rd *reader) allowed(ctx context.Context, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	key := accessKey{
+		user:       rd.user.UID + "/" + rd.user.Username + "/" + strings.Join(rd.user.Groups, ","),
+		attributes: attributes,
+	}
+	now := time.Now()
+	rd.auth.mutex.Lock()
+	decision, ok := rd.auth.access[key]
+	rd.auth.mutex.Unlock()
+	if ok && now.Before(decision.expires) {
+		return decision.allowed, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(rd.user.Extra))
+	for k, v := range rd.user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := rd.auth.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               rd.user.Username,
+			Groups:             rd.user.Groups,
+			UID:                rd.user.UID,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	rd.auth.mutex.Lock()
+	defer rd.auth.mutex.Unlock()
+	for k, d := range rd.auth.access {
+		if now.After(d.expires) {
+			delete(rd.auth.access, k)
+		}
+	}
+	rd.auth.access[key] = accessDecision{allowed: review.Status.Allowed, expires: now.Add(accessCacheTTL)}
+	return review.Status.Allowed, nil
+}
+
+// authorizedNamespaces returns the namespaces the reader can list the resource in,
+// or nil if the reader can list the resource cluster wide.
+// Namespaces are denied, if the access cannot be reviewed.
+func (rd *reader) authorizedNamespaces(ctx context.Context, gvr schema.GroupVersionResource, namespaces []string) []string {
+	list := func(namespace string) bool {
+		allowed, err := rd.allowed(ctx, authorizationv1.ResourceAttributes{
+			Verb:      "list",
+			Group:     gvr.Group,
+			Version:   gvr.Version,
+			Resource:  gvr.Resource,
+			Namespace: namespace,
+		})
+		if err != nil {
+			log.FromContext(ctx).Error(err, "unable to review access", "resource", gvr.String(), "namespace", namespace)
+		}
+		return allowed
+	}
+	if list("") {
+		return nil
+	}
+	authorized := []string{}
+	for _, namespace := range namespaces {
+		// cluster scoped objects need cluster wide access
+		if namespace != "" && list(namespace) {
+			authorized = append(authorized, namespace)
+		}
+	}
+	return authorized
+}
+
+// bearerToken returns the token of the Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requiredAccess returns the access needed to read the metrics of the request, ok is false for unknown paths.
+// Metrics of all stores need no access, they are scoped to the reader.
+func requiredAccess(r *http.Request) (attributes []authorizationv1.ResourceAttributes, ok bool) {
+	route, ok := parsePath(r.URL.Path)
+	if !ok || route == nil {
+		return nil, ok
+	}
+	resource := "metrics"
+	if route.namespace == "" {
+		resource = "clustermetrics"
+	}
+	return []authorizationv1.ResourceAttributes{{
+		Verb:      "get",
+		Group:     metricsv1.GroupVersion.Group,
+		Resource:  resource,
+		Namespace: route.namespace,
+		Name:      route.name,
+	}}, true
+}
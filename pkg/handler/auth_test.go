@@ -0,0 +1,180 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// newAuthClient returns a fake clientset authenticating the token "valid" as user "tenant", the tokens "admin" and "nobody" as users of the same name.
+// The tenant is allowed to get the Metric objects and to list the objects of test.cloud in namespace team-a,
+// the admin is allowed everything and nobody nothing.
+func newAuthClient() (*fake.Clientset, *[]authorizationv1.ResourceAttributes) {
+	reviewed := &[]authorizationv1.ResourceAttributes{}
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "error":
+			return true, nil, errors.New("api server unavailable")
+		case "valid":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "tenant", Groups: []string{"team-a"}},
+			}
+		case "admin", "nobody":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: review.Spec.Token},
+			}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := *review.Spec.ResourceAttributes
+		*reviewed = append(*reviewed, attributes)
+		tenant := review.Spec.User == "tenant" && attributes.Namespace == "team-a" &&
+			(attributes.Verb == "get" && attributes.Group == "metrics.crossplane.io" && attributes.Resource == "metrics" ||
+				attributes.Verb == "list" && attributes.Group == "test.cloud" && attributes.Resource == "objects")
+		review.Status.Allowed = tenant || review.Spec.User == "admin"
+		return true, review, nil
+	})
+	return client, reviewed
+}
+
+var _ = Describe("AuthHandler", func() {
+	var authHandler http.Handler
+	var reviewed *[]authorizationv1.ResourceAttributes
+	BeforeEach(func() {
+		var client *fake.Clientset
+		client, reviewed = newAuthClient()
+		authHandler = handler.NewAuthHandler(client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("metrics")) // nolint: errcheck
+		}))
+	})
+	request := func(target string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		authHandler.ServeHTTP(w, r)
+		return w
+	}
+
+	It("Should reject requests without a valid token", func() {
+		Expect(request("/x-metrics", "").Code).Should(Equal(http.StatusUnauthorized))
+		Expect(request("/x-metrics", "invalid").Code).Should(Equal(http.StatusUnauthorized))
+		Expect(*reviewed).Should(BeEmpty())
+	})
+	It("Should fail, if the token cannot be reviewed", func() {
+		Expect(request("/x-metrics", "error").Code).Should(Equal(http.StatusInternalServerError))
+	})
+	It("Should serve the metrics of a Metric the user can read", func() {
+		w := request("/x-metrics/namespaces/team-a/metrics/objects", "valid")
+
+		Expect(w.Code).Should(Equal(http.StatusOK))
+		Expect(w.Body.String()).Should(Equal("metrics"))
+		Expect(*reviewed).Should(Equal([]authorizationv1.ResourceAttributes{
+			{Verb: "get", Group: "metrics.crossplane.io", Resource: "metrics", Namespace: "team-a", Name: "objects"},
+		}))
+	})
+	It("Should forbid metrics of other namespaces and of ClusterMetrics", func() {
+		Expect(request("/x-metrics/namespaces/team-b/metrics/objects", "valid").Code).Should(Equal(http.StatusForbidden))
+		Expect(request("/x-metrics/clustermetrics/all", "valid").Code).Should(Equal(http.StatusForbidden))
+		Expect(*reviewed).Should(Equal([]authorizationv1.ResourceAttributes{
+			{Verb: "get", Group: "metrics.crossplane.io", Resource: "metrics", Namespace: "team-b", Name: "objects"},
+			{Verb: "get", Group: "metrics.crossplane.io", Resource: "clustermetrics", Name: "all"},
+		}))
+	})
+	It("Should serve the metrics of all stores without reviewing the access to Metric objects", func() {
+		Expect(request("/x-metrics", "valid").Code).Should(Equal(http.StatusOK))
+		Expect(*reviewed).Should(BeEmpty())
+	})
+	It("Should cache access decisions", func() {
+		Expect(request("/x-metrics/namespaces/team-a/metrics/objects", "valid").Code).Should(Equal(http.StatusOK))
+		Expect(request("/x-metrics/namespaces/team-a/metrics/objects", "valid").Code).Should(Equal(http.StatusOK))
+		Expect(*reviewed).Should(HaveLen(1))
+	})
+	It("Should not find unknown paths", func() {
+		Expect(request("/x-metrics/unknown", "valid").Code).Should(Equal(http.StatusNotFound))
+	})
+})
+
+var _ = Describe("Reader scoped metrics", func() {
+	var authHandler http.Handler
+	var reviewed *[]authorizationv1.ResourceAttributes
+	BeforeEach(func() {
+		var objects []runtime.Object
+		for _, namespace := range []string{"team-a", "team-b", ""} {
+			obj := newTestObject("object-"+namespace, map[string]interface{}{})
+			obj.SetNamespace(namespace)
+			obj.SetUID(types.UID("object-" + namespace))
+			objects = append(objects, obj)
+		}
+		h := handler.NewManagedMetricsHandlerWithStore(newFakeClient(objects...), store.NewXMetricsStore)
+		mmHandler := &h
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), "test", testGVR, "", handler.StoreOptions{NamespaceFilter: true, Namespaces: []string{"team-a", "team-b", ""}})
+		DeferCleanup(func() {
+			close(channel)
+		})
+		Eventually(func() string {
+			return getPath(mmHandler, handler.MetricsPath)
+		}).Should(HaveSuffix("x_metric_resources_count_total 3\n"))
+
+		var client *fake.Clientset
+		client, reviewed = newAuthClient()
+		authHandler = handler.NewAuthHandler(client, mmHandler)
+	})
+	scrape := func(target string, token string) string {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		authHandler.ServeHTTP(w, r)
+		Expect(w.Code).Should(Equal(http.StatusOK))
+		return w.Body.String()
+	}
+
+	It("Should only write the objects of namespaces the reader can list", func() {
+		data := scrape(handler.MetricsPath, "valid")
+
+		Expect(data).Should(ContainSubstring(`test_created{name="object-team-a",namespace="team-a"} `))
+		Expect(data).ShouldNot(ContainSubstring("team-b"))
+		Expect(data).ShouldNot(ContainSubstring(`name="object-"`))
+		Expect(data).Should(ContainSubstring("\ntest_resource_count 1\n"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+		Expect(*reviewed).Should(ConsistOf(
+			authorizationv1.ResourceAttributes{Verb: "list", Group: "test.cloud", Version: "v1", Resource: "objects"},
+			authorizationv1.ResourceAttributes{Verb: "list", Group: "test.cloud", Version: "v1", Resource: "objects", Namespace: "team-a"},
+			authorizationv1.ResourceAttributes{Verb: "list", Group: "test.cloud", Version: "v1", Resource: "objects", Namespace: "team-b"},
+		))
+	})
+	It("Should write all objects, if the reader can list them cluster wide", func() {
+		data := scrape(handler.MetricsPath, "admin")
+
+		Expect(data).Should(ContainSubstring(`name="object-team-b"`))
+		Expect(data).Should(ContainSubstring(`name="object-"`))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 3\n"))
+		Expect(*reviewed).Should(HaveLen(1))
+	})
+	It("Should skip stores without objects the reader can list", func() {
+		data := scrape(handler.MetricsPath, "nobody")
+
+		Expect(data).ShouldNot(ContainSubstring("test"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 0\n"))
+	})
+})
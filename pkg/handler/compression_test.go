@@ -0,0 +1,201 @@
+package handler_test
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+func get(mmHandler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/x-metrics", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	mmHandler.ServeHTTP(w, r)
+	return w
+}
+
+var _ = Describe("Compression", func() {
+	var mmHandler *handler.ManagedMetricsHandler
+	BeforeEach(func(ctx SpecContext) {
+		h := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), store.NewXMetricsStore)
+		mmHandler = &h
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), "test", testGVR, "", handler.StoreOptions{})
+		DeferCleanup(func() {
+			close(channel)
+		})
+		Eventually(func() string {
+			return get(mmHandler, "").Body.String()
+		}).Should(ContainSubstring("\ntest_created{"))
+	})
+	It("Should not compress without Accept-Encoding", func() {
+		w := get(mmHandler, "")
+
+		Expect(w.Header().Get("Content-Encoding")).Should(BeEmpty())
+		Expect(w.Header().Get("Vary")).Should(Equal("Accept-Encoding"))
+		Expect(w.Body.String()).Should(ContainSubstring("x_metric_resources_count_total 1\n"))
+	})
+	It("Should compress, if gzip is accepted", func() {
+		plain := get(mmHandler, "").Body.String()
+		w := get(mmHandler, "deflate, gzip;q=0.8")
+
+		Expect(w.Header().Get("Content-Encoding")).Should(Equal("gzip"))
+		reader, err := gzip.NewReader(w.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		data, err := io.ReadAll(reader)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal(plain))
+	})
+	It("Should serve the cached compressed output of cached stores next to streamed stores", func(ctx SpecContext) {
+		cached := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), store.NewCachedXMetricsStore)
+		channels := []chan struct{}{
+			cached.RegisterAndAddMetricStoreForGVR(ctx, "cached_a", testGVR, "", handler.StoreOptions{}),
+			cached.RegisterAndAddMetricStoreForGVR(ctx, "cached_b", testGVR, "", handler.StoreOptions{}),
+		}
+		defer func() {
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+		Eventually(func() string {
+			return get(&cached, "").Body.String()
+		}).Should(HaveSuffix("x_metric_resources_count_total 2\n"))
+
+		plain := get(&cached, "").Body.String()
+		for i := 0; i < 2; i++ {
+			w := get(&cached, "gzip")
+			Expect(w.Header().Get("Content-Encoding")).Should(Equal("gzip"))
+			// the cached members and the compressed rest of the response are concatenated
+			reader, err := gzip.NewReader(w.Body)
+			Expect(err).ShouldNot(HaveOccurred())
+			data, err := io.ReadAll(reader)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(data)).Should(Equal(plain))
+		}
+	})
+	It("Should not compress, if gzip is rejected", func() {
+		w := get(mmHandler, "gzip;q=0, identity")
+
+		Expect(w.Header().Get("Content-Encoding")).Should(BeEmpty())
+		Expect(w.Body.String()).Should(ContainSubstring("x_metric_resources_count_total 1\n"))
+	})
+})
+
+// discardResponseWriter counts the bytes of the response
+type discardResponseWriter struct {
+	header http.Header
+	size   int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(d []byte) (int, error) {
+	w.size += len(d)
+	return len(d), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {}
+
+// newBenchmarkHandler returns a handler serving 50k objects in 100 metric stores, one for each namespace
+func newBenchmarkHandler(b *testing.B, newStore func([]store.FamilyHeader, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore) (*handler.ManagedMetricsHandler, func()) {
+	const namespaces = 100
+	const objectsPerNamespace = 500
+
+	objects := make([]runtime.Object, 0, namespaces*objectsPerNamespace)
+	for i := 0; i < namespaces*objectsPerNamespace; i++ {
+		obj := newTestObject(fmt.Sprintf("object-%d", i), map[string]interface{}{
+			"spec": map[string]interface{}{
+				"forProvider": map[string]interface{}{
+					"region": "eu-central-1",
+				},
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "True", "reason": "Available", "lastTransitionTime": "2023-01-02T03:04:05Z"},
+					map[string]interface{}{"type": "Synced", "status": "True", "reason": "ReconcileSuccess", "lastTransitionTime": "2023-01-02T03:04:05Z"},
+				},
+			},
+		})
+		obj.SetNamespace(fmt.Sprintf("namespace-%d", i%namespaces))
+		obj.SetUID(types.UID(obj.GetName()))
+		obj.SetLabels(map[string]string{"crossplane.io/claim-name": obj.GetName(), "crossplane.io/claim-namespace": obj.GetNamespace()})
+		objects = append(objects, obj)
+	}
+	mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(objects...), newStore)
+
+	var channels []chan struct{}
+	for i := 0; i < namespaces; i++ {
+		channels = append(channels, mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), fmt.Sprintf("test%d", i), testGVR, fmt.Sprintf("namespace-%d", i), handler.StoreOptions{
+			InfoMappings: []handler.InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}},
+		}))
+	}
+	synced := fmt.Sprintf("x_metric_resources_count_total %d\n", len(objects))
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		w := httptest.NewRecorder()
+		mmHandler.ServeHTTP(w, nil)
+		if strings.HasSuffix(w.Body.String(), synced) {
+			break
+		}
+		if time.Now().After(deadline) {
+			b.Fatal("metric stores did not sync")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return &mmHandler, func() {
+		for _, c := range channels {
+			close(c)
+		}
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	for _, s := range []struct {
+		name     string
+		newStore func([]store.FamilyHeader, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore
+	}{
+		{name: "streamed", newStore: store.NewXMetricsStore},
+		{name: "cached", newStore: store.NewCachedXMetricsStore},
+	} {
+		b.Run(s.name, func(b *testing.B) {
+			mmHandler, stop := newBenchmarkHandler(b, s.newStore)
+			defer stop()
+
+			for _, encoding := range []string{"identity", "gzip"} {
+				b.Run(encoding, func(b *testing.B) {
+					r := httptest.NewRequest(http.MethodGet, "/x-metrics", nil)
+					r.Header.Set("Accept-Encoding", encoding)
+					var size int
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						w := &discardResponseWriter{header: http.Header{}}
+						mmHandler.ServeHTTP(w, r)
+						size = w.size
+					}
+					b.ReportMetric(float64(size), "payload-bytes")
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PruneFunc exposes the transform pruning the objects of a store to the tests
+func PruneFunc(opts StoreOptions) cache.TransformFunc {
+	return pruneFunc(prunePaths(opts))
+}
+
+// Informers returns the number of running shared informers
+func (m *ManagedMetricsHandler) Informers() int {
+	return m.informers.count()
+}
+
+// CachedObjects returns the objects cached by the informers of the resource
+func (m *ManagedMetricsHandler) CachedObjects(gvr schema.GroupVersionResource) []interface{} {
+	m.informers.mutex.Lock()
+	defer m.informers.mutex.Unlock()
+	var objects []interface{}
+	for key, shared := range m.informers.informers {
+		if key.gvr == gvr {
+			objects = append(objects, shared.informer.GetStore().List()...)
+		}
+	}
+	return objects
+}
+
+// ListPages exposes the paged list of the handler to the tests
+func (m *ManagedMetricsHandler) ListPages(ctx context.Context, ops metav1.ListOptions, list func(metav1.ListOptions) (runtime.Object, error)) (runtime.Object, error) {
+	return m.listPages(ctx, ops, list)
+}
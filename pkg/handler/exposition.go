@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+
+	store "github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// Metric family types of the OpenMetrics format, which are written as gauges in the text format.
+// The _info families are gauges in both formats: as OpenMetrics info families named without the suffix
+// they would clash with the gauges of the same name, e.g. <metric>_ready_info with <metric>_ready.
+const (
+	typeStateSet = "stateset"
+	typeUnknown  = "unknown"
+)
+
+var (
+	textHelpEscaper        = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	openMetricsHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// negotiateFormat returns the exposition format accepted by the scraper.
+// The text format is used, if OpenMetrics is not requested.
+func negotiateFormat(r *http.Request) expfmt.Format {
+	if r != nil && expfmt.NegotiateIncludingOpenMetrics(r.Header) == expfmt.FmtOpenMetrics {
+		return expfmt.FmtOpenMetrics
+	}
+	return expfmt.FmtText
+}
+
+// newFamilyHeader returns the headers of a metric family exposing the same series in both formats
+func newFamilyHeader(name string, typ string, unit string, help string) store.FamilyHeader {
+	return store.FamilyHeader{
+		Name:        name,
+		Text:        textHeader(name, typ, help),
+		OpenMetrics: openMetricsHeader(name, typ, unit, help),
+	}
+}
+
+func textHeader(name string, typ string, help string) string {
+	switch typ {
+	case typeStateSet, typeUnknown:
+		typ = string(metric.Gauge)
+	}
+	return fmt.Sprintf("# TYPE %[1]s %[2]s\n# HELP %[1]s %[3]s", name, typ, textHelpEscaper.Replace(help))
+}
+
+func openMetricsHeader(name string, typ string, unit string, help string) string {
+	header := fmt.Sprintf("# TYPE %s %s\n", name, typ)
+	if unit != "" {
+		header += fmt.Sprintf("# UNIT %s %s\n", name, unit)
+	}
+	return header + fmt.Sprintf("# HELP %s %s", name, openMetricsHelpEscaper.Replace(help))
+}
+
+// valueMetricName returns the name of the value metric with the unit as suffix.
+// The unit is placed in front of the _total suffix of counters.
+func valueMetricName(v ValueMappings) string {
+	name := GetValidLabel(v.Name)
+	if v.Unit == "" {
+		return name
+	}
+	total := v.Type == string(metric.Counter) && strings.HasSuffix(name, "_total")
+	name = strings.TrimSuffix(name, "_total")
+	if !strings.HasSuffix(name, "_"+v.Unit) {
+		name += "_" + v.Unit
+	}
+	if total {
+		name += "_total"
+	}
+	return name
+}
+
+// isOpenMetricsCounter returns true, if the value metric is exposed as counter in OpenMetrics.
+// OpenMetrics requires the _total suffix for the series of counters, other counters are of type unknown.
+func isOpenMetricsCounter(v ValueMappings) bool {
+	return v.Type == string(metric.Counter) && strings.HasSuffix(valueMetricName(v), "_total")
+}
+
+// familyList renders several metric families as one, e.g. the _total and _created series of a counter
+type familyList []metric.FamilyInterface
+
+func (l familyList) Inspect(inspect func(metric.Family)) {
+	for _, f := range l {
+		f.Inspect(inspect)
+	}
+}
+
+func (l familyList) ByteSlice() []byte {
+	var b []byte
+	for _, f := range l {
+		b = append(b, f.ByteSlice()...)
+	}
+	return b
+}
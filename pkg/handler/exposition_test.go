@@ -0,0 +1,219 @@
+package handler_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+const openMetricsAccept = "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+
+// serve registers a metric store for testGVR and returns the response once all objects are synced
+func serve(ctx SpecContext, accept string, opts handler.StoreOptions, objects ...runtime.Object) *httptest.ResponseRecorder {
+	mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(objects...), store.NewXMetricsStore)
+	channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", opts)
+	DeferCleanup(func() {
+		close(channel)
+	})
+	var w *httptest.ResponseRecorder
+	Eventually(func() int {
+		r := httptest.NewRequest(http.MethodGet, "/x-metrics", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w = httptest.NewRecorder()
+		mmHandler.ServeHTTP(w, r)
+		return strings.Count(w.Body.String(), "\ntest_created{")
+	}).Should(Equal(len(objects)))
+	return w
+}
+
+var omSample = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{.*\})? (\S+)$`)
+
+// checkOpenMetrics validates the OpenMetrics specific rules of the exposition and
+// returns it in the text format, so that it can be parsed by the expfmt parser
+func checkOpenMetrics(data string) string {
+	Expect(data).Should(HaveSuffix("\n# EOF\n"))
+	Expect(strings.Count(data, "# EOF")).Should(Equal(1))
+
+	// every family is declared once, samples are named by their family
+	types := map[string]string{}
+	samples := map[string]string{}
+	var family string
+	var text strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimSuffix(data, "# EOF\n")))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.SplitN(line, " ", 4)
+		switch {
+		case strings.HasPrefix(line, "# TYPE "):
+			family = fields[2]
+			Expect(types).ShouldNot(HaveKey(family), "family %s is declared twice", family)
+			types[family] = fields[3]
+			switch fields[3] {
+			case "stateset":
+				line = "# TYPE " + family + " gauge"
+			case "unknown":
+				line = "# TYPE " + family + " untyped"
+			case "counter":
+				// the expfmt parser expects the samples of a counter to be named like the family
+				line = "# TYPE " + family + "_total counter"
+			}
+		case strings.HasPrefix(line, "# UNIT "):
+			Expect(fields[2]).Should(Equal(family))
+			Expect(family).Should(HaveSuffix("_" + fields[3]))
+			continue
+		case strings.HasPrefix(line, "# HELP "):
+			Expect(fields[2]).Should(Equal(family))
+			// double quotes are only escaped in the help of OpenMetrics
+			help := strings.ReplaceAll(fields[3], `\"`, `"`)
+			name := family
+			if types[family] == "counter" {
+				name += "_total"
+			}
+			line = "# HELP " + name + " " + help
+		default:
+			match := omSample.FindStringSubmatch(line)
+			Expect(match).ShouldNot(BeNil(), "invalid sample %q", line)
+			name, labels, value := match[1], match[2], match[3]
+			switch types[family] {
+			case "counter":
+				Expect(name).Should(BeElementOf(family+"_total", family+"_created"))
+				if name == family+"_created" {
+					continue
+				}
+			case "info":
+				Expect(name).Should(Equal(family + "_info"))
+				Expect(value).Should(Equal("1"))
+			case "stateset":
+				Expect(name).Should(Equal(family))
+				Expect(labels).Should(ContainSubstring(family + `="`))
+				Expect(value).Should(BeElementOf("0", "1"))
+			default:
+				Expect(name).Should(Equal(family))
+			}
+			samples[name] = family
+		}
+		text.WriteString(line + "\n")
+	}
+	// the samples of a family must not be named like another family
+	for name, owner := range samples {
+		if _, ok := types[name]; ok {
+			Expect(name).Should(Equal(owner), "sample %s of family %s clashes with family %s", name, owner, name)
+		}
+	}
+	return text.String()
+}
+
+func parseText(data string) map[string]*dto.MetricFamily {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(data))
+	Expect(err).ShouldNot(HaveOccurred())
+	return families
+}
+
+var _ = Describe("Exposition formats", func() {
+	fields := func() map[string]interface{} {
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"size":     "10Gi",
+				"requests": int64(42),
+				"restarts": int64(3),
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":               "Ready",
+						"status":             "False",
+						"reason":             "Creating",
+						"message":            `waiting for "db"`,
+						"lastTransitionTime": "2023-01-02T03:04:05Z",
+					},
+					map[string]interface{}{
+						"type":               "Synced",
+						"status":             "True",
+						"reason":             "ReconcileSuccess",
+						"lastTransitionTime": "2023-01-02T03:04:06Z",
+					},
+				},
+			},
+		}
+	}
+	object := func(name string) *unstructured.Unstructured {
+		obj := newTestObject(name, fields())
+		obj.SetUID(types.UID(name))
+		return obj
+	}
+	opts := handler.StoreOptions{
+		InfoMappings:       []handler.InfoMappings{{FieldPath: "spec.size", Label: "size"}},
+		ReadyMessageLength: 64,
+		ValueMappings: []handler.ValueMappings{
+			{Name: "size", FieldPath: "spec.size", Unit: "bytes", Help: "Size of \"the\" volume\nin bytes"},
+			{Name: "requests_total", FieldPath: "spec.requests", Type: "counter"},
+			{Name: "restarts", FieldPath: "spec.restarts", Type: "counter"},
+		},
+	}
+
+	It("Should write the text format by default", func(ctx SpecContext) {
+		w := serve(ctx, "", opts, object("a"), object("b"))
+
+		Expect(w.Header().Get("Content-Type")).Should(Equal(string(expfmt.FmtText)))
+		Expect(w.Body.String()).ShouldNot(ContainSubstring("# EOF"))
+		families := parseText(w.Body.String())
+
+		Expect(families["test_info"].GetType()).Should(Equal(dto.MetricType_GAUGE))
+		Expect(families["test_condition"].GetType()).Should(Equal(dto.MetricType_GAUGE))
+		Expect(families["test_condition"].GetMetric()).Should(HaveLen(4))
+		Expect(families["test_size_bytes"].GetHelp()).Should(Equal("Size of \"the\" volume\nin bytes"))
+		Expect(families["test_requests_total"].GetType()).Should(Equal(dto.MetricType_COUNTER))
+		Expect(families["test_restarts"].GetType()).Should(Equal(dto.MetricType_COUNTER))
+		Expect(families["x_metric_resources_count_total"].GetMetric()[0].GetGauge().GetValue()).Should(Equal(2.0))
+	})
+	It("Should write OpenMetrics, if requested", func(ctx SpecContext) {
+		w := serve(ctx, openMetricsAccept, opts, object("a"), object("b"))
+
+		Expect(w.Header().Get("Content-Type")).Should(Equal(string(expfmt.FmtOpenMetrics)))
+		data := w.Body.String()
+		// info families keep the names of their series and are gauges, an info family test would clash with the gauge test
+		Expect(data).Should(ContainSubstring("# TYPE test gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE test_info gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE test_ready gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE test_ready_info gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE test_condition stateset\n"))
+		Expect(data).Should(ContainSubstring(`test_condition{name="a",type="Ready",reason="Creating",test_condition="False"} 1`))
+		Expect(data).Should(ContainSubstring(`test_condition{name="a",type="Ready",reason="Creating",test_condition="True"} 0`))
+		Expect(data).Should(ContainSubstring("# TYPE test_size_bytes gauge\n# UNIT test_size_bytes bytes\n"))
+		Expect(data).Should(ContainSubstring(`# HELP test_size_bytes Size of \"the\" volume\nin bytes`))
+		Expect(data).Should(ContainSubstring("# TYPE test_requests counter\n"))
+		Expect(data).Should(ContainSubstring(`test_requests_total{name="a"} 42`))
+		Expect(data).Should(ContainSubstring(`test_requests_created{name="a"} `))
+		Expect(data).Should(ContainSubstring("# TYPE test_restarts unknown\n"))
+
+		families := parseText(checkOpenMetrics(data))
+		Expect(families["test_condition"].GetMetric()).Should(HaveLen(12))
+		Expect(families["test_requests_total"].GetMetric()).Should(HaveLen(2))
+		Expect(families["test_restarts"].GetType()).Should(Equal(dto.MetricType_UNTYPED))
+	})
+	It("Should keep the series names of the text format in OpenMetrics", func(ctx SpecContext) {
+		text := parseText(serve(ctx, "text/plain;version=0.0.4", opts, object("a")).Body.String())
+		openMetrics := parseText(checkOpenMetrics(serve(ctx, openMetricsAccept, opts, object("a")).Body.String()))
+
+		for name := range text {
+			Expect(openMetrics).Should(HaveKey(name))
+		}
+		Expect(openMetrics).Should(HaveLen(len(text)))
+	})
+})
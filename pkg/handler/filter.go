@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	store "github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// Filter selects the metric stores written by a scrape and the objects and families written by each store.
+// An empty field selects everything.
+type Filter struct {
+	// Metrics selects the metric stores by the name of the metric
+	Metrics []string
+	// Groups selects the metric stores by the api group of their resource
+	Groups []string
+	store.Filter
+	// stores restricts the metric stores to the stores of a metric object, if not nil
+	stores map[string]struct{}
+	// authorize returns the namespaces of the store the reader is authorized for, or nil if the reader is authorized for all namespaces
+	authorize func(gvr schema.GroupVersionResource, namespaces []string) []string
+}
+
+// filterFromQuery returns the filter of the query parameters metric, group, namespace and family.
+// Each parameter can be repeated or hold a comma separated list, e.g. ?family=ready,synced
+func filterFromQuery(r *http.Request) Filter {
+	if r == nil || r.URL == nil {
+		return Filter{}
+	}
+	query := r.URL.Query()
+	return Filter{
+		Metrics: queryValues(query["metric"]),
+		Groups:  queryValues(query["group"]),
+		Filter: store.Filter{
+			Namespaces: queryValues(query["namespace"]),
+			Families:   queryValues(query["family"]),
+		},
+	}
+}
+
+func queryValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (f Filter) matchStore(name string, gvr schema.GroupVersionResource) bool {
+	if _, ok := f.stores[name]; f.stores != nil && !ok {
+		return false
+	}
+	return matchAny(f.Metrics, name) && matchAny(f.Groups, gvr.Group)
+}
+
+func matchAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+func getPath(mmHandler http.Handler, target string) string {
+	w := httptest.NewRecorder()
+	mmHandler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w.Body.String()
+}
+
+var _ = Describe("Query parameter filters", func() {
+	var mmHandler *handler.ManagedMetricsHandler
+	BeforeEach(func() {
+		a := newTestObject("a", map[string]interface{}{})
+		a.SetNamespace("ns1")
+		a.SetUID(types.UID("a"))
+		b := newTestObject("b", map[string]interface{}{})
+		b.SetNamespace("ns2")
+		b.SetUID(types.UID("b"))
+		h := handler.NewManagedMetricsHandlerWithStore(newFakeClient(a, b), store.NewXMetricsStore)
+		mmHandler = &h
+		for _, name := range []string{"first", "second"} {
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), name, testGVR, "", handler.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns1", "ns2"}})
+			DeferCleanup(func() {
+				close(channel)
+			})
+		}
+		Eventually(func() string {
+			return getPath(mmHandler, "/x-metrics")
+		}).Should(HaveSuffix("x_metric_resources_count_total 4\n"))
+	})
+	It("Should only write the selected metric", func() {
+		data := getPath(mmHandler, "/x-metrics?metric=second")
+
+		Expect(data).Should(ContainSubstring(`second_created{name="a",namespace="ns1"} `))
+		Expect(data).ShouldNot(ContainSubstring("first"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 2\n"))
+	})
+	It("Should only write the stores of the selected group", func() {
+		Expect(getPath(mmHandler, "/x-metrics?group=test.cloud")).Should(HaveSuffix("x_metric_resources_count_total 4\n"))
+
+		data := getPath(mmHandler, "/x-metrics?group=other.cloud")
+		Expect(data).ShouldNot(ContainSubstring("first"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 0\n"))
+	})
+	It("Should only write the objects of the selected namespace", func() {
+		data := getPath(mmHandler, "/x-metrics?namespace=ns2")
+
+		Expect(data).Should(ContainSubstring(`first_created{name="b",namespace="ns2"} `))
+		Expect(data).ShouldNot(ContainSubstring(`name="a"`))
+		Expect(data).Should(ContainSubstring("\nfirst_resource_count 1\n"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 2\n"))
+	})
+	It("Should only write the selected families", func() {
+		data := getPath(mmHandler, "/x-metrics?family=ready,synced&family=first_created")
+
+		Expect(data).Should(ContainSubstring("# TYPE first_ready gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE second_synced gauge\n"))
+		Expect(data).Should(ContainSubstring("# TYPE first_created gauge\n"))
+		Expect(data).ShouldNot(ContainSubstring("# TYPE second_created gauge\n"))
+		Expect(data).ShouldNot(ContainSubstring("_ready_time"))
+		Expect(data).ShouldNot(ContainSubstring("_info"))
+		Expect(data).ShouldNot(ContainSubstring("_resource_count "))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 4\n"))
+	})
+	It("Should combine the filters", func() {
+		data := getPath(mmHandler, "/x-metrics?metric=first&namespace=ns1&family=created")
+
+		Expect(regexp.MustCompile(`(?m)^# TYPE (\S+)`).FindAllStringSubmatch(data, -1)).Should(Equal([][]string{
+			{"# TYPE first_created", "first_created"},
+			{"# TYPE x_metric_resources_count_total", "x_metric_resources_count_total"},
+		}))
+		Expect(data).Should(MatchRegexp(`\nfirst_created\{name="a",namespace="ns1"\} \S+\n# TYPE x_metric`))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+	})
+})
@@ -21,17 +21,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -42,118 +53,352 @@ import (
 
 type IManagedMetricsHandler interface {
 	ServeHTTP(writer http.ResponseWriter, r *http.Request)
-	RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) chan struct{}
+	RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts StoreOptions) chan struct{}
+	MigrateMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts StoreOptions) chan struct{}
 	RemoveMetricStore(name string)
+	WatchError(gvr schema.GroupVersionResource) error
+	NotifyWatchState(notify func(gvr schema.GroupVersionResource))
+	UnsyncedResources(metricName string) []schema.GroupVersionResource
+	UpdateNamespaces(metricName string, namespaces []string) bool
 }
 
 type ManagedMetricsHandler struct {
-	metricsWriter   map[string]store.IXMetricsStore
-	Client          dynamic.Interface
-	callbacks       map[string]func() (schema.GroupVersionResource, int)
-	newStoreHandler func([]string, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore
+	registry  *MetricStoreRegistry
+	informers *informerFactory
+	syncs     *syncTracker
+	Client    dynamic.Interface
+	// MetadataClient lists and watches the objects of metadata only stores, the Client is used if it is nil
+	MetadataClient metadata.Interface
+	// ListPageSize is the number of objects requested per page of a list, 0 leaves the paging to the reflector
+	ListPageSize int64
+	// ResyncPeriod is the resync period of stores without one in their options, 0 disables resyncs
+	ResyncPeriod time.Duration
+	// WatchBackoff is the delay added before a list or watch is retried after failures, a zero Duration adds none
+	WatchBackoff    wait.Backoff
+	newStoreHandler func([]store.FamilyHeader, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore
+	consumers       ConsumerLookup
 }
 
 type InfoMappings struct {
 	FieldPath string
 	Label     string
 }
+
+// ValueMappings describes a metric family with values read from a field of the object
+type ValueMappings struct {
+	Name      string
+	FieldPath string
+	Labels    []InfoMappings
+	Help      string
+	Type      string
+	// Unit is appended to the name and exposed as unit in OpenMetrics
+	Unit string
+}
+
+// StoreOptions configures the metric families generated for the objects of a metric store
+type StoreOptions struct {
+	InfoMappings  []InfoMappings
+	ValueMappings []ValueMappings
+	// ConditionTypes restricts the condition metrics to the listed types, all conditions are exposed if empty
+	ConditionTypes []string
+	// ReadyMessageLength enables the _ready_info metric with the message of the Ready condition capped to this length
+	ReadyMessageLength int
+	// LabelSelector and FieldSelector restrict the listed and watched objects
+	LabelSelector string
+	FieldSelector string
+	// NamespaceFilter restricts the objects of a cluster wide store to the listed Namespaces
+	NamespaceFilter bool
+	Namespaces      []string
+	// ResyncPeriod overrides the resync period of the handler for the store
+	ResyncPeriod time.Duration
+	// Metadata watches only the metadata of the objects. The metrics based on the status
+	// and the value metrics are not exposed, info labels can only be read from the metadata.
+	Metadata bool
+}
+
+// MergeStoreOptions combines the options of all consumers of a shared metric store.
+// Info mappings are merged by label and value mappings by name, the first definition wins.
+func MergeStoreOptions(opts ...StoreOptions) StoreOptions {
+	// the store watches only metadata, if no consumer needs the full objects
+	merged := StoreOptions{Metadata: len(opts) > 0}
+	labels := map[string]struct{}{}
+	names := map[string]struct{}{}
+	conditionTypes := map[string]struct{}{}
+	allConditions := false
+	for _, o := range opts {
+		// selectors are part of the metric name, all consumers of a store share the same selectors
+		if merged.LabelSelector == "" {
+			merged.LabelSelector = o.LabelSelector
+		}
+		if merged.FieldSelector == "" {
+			merged.FieldSelector = o.FieldSelector
+		}
+		if !merged.NamespaceFilter && o.NamespaceFilter {
+			merged.NamespaceFilter = true
+			merged.Namespaces = o.Namespaces
+		}
+		if !o.Metadata {
+			merged.Metadata = false
+		}
+		// the store resyncs as often as its most demanding consumer requests
+		if o.ResyncPeriod > 0 && (merged.ResyncPeriod == 0 || o.ResyncPeriod < merged.ResyncPeriod) {
+			merged.ResyncPeriod = o.ResyncPeriod
+		}
+		if o.ReadyMessageLength > merged.ReadyMessageLength {
+			merged.ReadyMessageLength = o.ReadyMessageLength
+		}
+		if len(o.ConditionTypes) == 0 {
+			allConditions = true
+		}
+		for _, t := range o.ConditionTypes {
+			conditionTypes[t] = struct{}{}
+		}
+		for _, m := range o.InfoMappings {
+			if _, ok := labels[m.Label]; ok {
+				continue
+			}
+			labels[m.Label] = struct{}{}
+			merged.InfoMappings = append(merged.InfoMappings, m)
+		}
+		for _, v := range o.ValueMappings {
+			if _, ok := names[v.Name]; ok {
+				continue
+			}
+			names[v.Name] = struct{}{}
+			merged.ValueMappings = append(merged.ValueMappings, v)
+		}
+	}
+	sort.Slice(merged.InfoMappings, func(i, j int) bool {
+		return merged.InfoMappings[i].Label < merged.InfoMappings[j].Label
+	})
+	sort.Slice(merged.ValueMappings, func(i, j int) bool {
+		return merged.ValueMappings[i].Name < merged.ValueMappings[j].Name
+	})
+	if !allConditions {
+		for t := range conditionTypes {
+			merged.ConditionTypes = append(merged.ConditionTypes, t)
+		}
+		sort.Strings(merged.ConditionTypes)
+	}
+	return merged
+}
+
+// ParseFieldSelector parses a field selector of a metric store.
+// The selector is matched against the name and the namespace of the objects, the only fields the api server supports for custom resources, other fields are rejected.
+func ParseFieldSelector(selector string) (fields.Selector, error) {
+	parsed, err := fields.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range parsed.Requirements() {
+		if r.Field != "metadata.name" && r.Field != "metadata.namespace" {
+			return nil, fmt.Errorf("unsupported field %q in field selector, only metadata.name and metadata.namespace are supported", r.Field)
+		}
+	}
+	return parsed, nil
+}
+
+// reservedLabels identify the object of every series, mapped fields must not be exposed as labels of the same name
+var reservedLabels = map[string]struct{}{"name": {}, "namespace": {}}
+
+// ValidateInfoMappings returns an error if a mapping is exposed as a reserved label or as the label of another mapping.
+// Duplicate labels make the series invalid, which fails the whole scrape.
+func ValidateInfoMappings(mappings []InfoMappings) error {
+	labels := map[string]struct{}{}
+	for _, m := range mappings {
+		label := GetValidLabel(m.Label)
+		if _, ok := reservedLabels[label]; ok {
+			return fmt.Errorf("label %q is reserved", m.Label)
+		}
+		if _, ok := labels[label]; ok {
+			return fmt.Errorf("label %q is mapped more than once", m.Label)
+		}
+		labels[label] = struct{}{}
+	}
+	return nil
+}
+
+// reservedFamilies are the suffixes of the families of every store, value metrics must not be named like them
+var reservedFamilies = map[string]struct{}{
+	"":                                {},
+	"_created":                        {},
+	"_labels":                         {},
+	"_info":                           {},
+	"_ready":                          {},
+	"_ready_info":                     {},
+	"_ready_time":                     {},
+	"_synced":                         {},
+	"_synced_time":                    {},
+	"_condition":                      {},
+	"_condition_last_transition_time": {},
+	"_resource_count":                 {},
+	"_resource_count_by_namespace":    {},
+	"_resource_count_by_status":       {},
+}
+
+// ValidateValueMappings returns an error if a value metric is named like a family of every store or like another value metric,
+// or if its labels are invalid. The names include the unit and, for counters in OpenMetrics, the family and the _created series.
+func ValidateValueMappings(mappings []ValueMappings) error {
+	families := map[string]string{}
+	for _, v := range mappings {
+		if err := ValidateInfoMappings(v.Labels); err != nil {
+			return fmt.Errorf("value metric %q: %w", v.Name, err)
+		}
+		names := []string{"_" + valueMetricName(v)}
+		if isOpenMetricsCounter(v) {
+			family := strings.TrimSuffix(names[0], "_total")
+			names = append(names, family, family+"_created")
+		}
+		for _, name := range names {
+			if _, ok := reservedFamilies[name]; ok {
+				return fmt.Errorf("value metric %q is named like the %q metric of every resource", v.Name, strings.TrimPrefix(name, "_"))
+			}
+			if other, ok := families[name]; ok {
+				return fmt.Errorf("value metric %q is named like value metric %q", v.Name, other)
+			}
+			families[name] = v.Name
+		}
+	}
+	return nil
+}
+
 type crossplaneStatus struct {
-	ready      float64
-	synced     float64
-	readyTime  time.Time
-	syncedTime time.Time
+	ready        float64
+	synced       float64
+	readyTime    time.Time
+	syncedTime   time.Time
+	readyReason  string
+	syncedReason string
+	readyMessage string
 }
 
 func NewManagedMetricsHandler(dc dynamic.Interface) ManagedMetricsHandler {
 	return ManagedMetricsHandler{
-		metricsWriter:   map[string]store.IXMetricsStore{},
+		registry:        NewMetricStoreRegistry(),
+		informers:       newInformerFactory(),
+		syncs:           newSyncTracker(),
 		Client:          dc,
-		callbacks:       map[string]func() (schema.GroupVersionResource, int){},
 		newStoreHandler: store.NewXMetricsStore,
 	}
 }
 
-func NewManagedMetricsHandlerWithStore(dc dynamic.Interface, storeHandler func([]string, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore) ManagedMetricsHandler {
+func NewManagedMetricsHandlerWithStore(dc dynamic.Interface, storeHandler func([]store.FamilyHeader, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore) ManagedMetricsHandler {
 	return ManagedMetricsHandler{
-		metricsWriter:   map[string]store.IXMetricsStore{},
+		registry:        NewMetricStoreRegistry(),
+		informers:       newInformerFactory(),
+		syncs:           newSyncTracker(),
 		Client:          dc,
-		callbacks:       map[string]func() (schema.GroupVersionResource, int){},
 		newStoreHandler: storeHandler,
 	}
 }
 
+// ServeHTTP writes the metrics of all stores in the text format or in OpenMetrics, if requested by the scraper.
+// The path of a metric object serves only the stores of the object, see ConsumerPath.
+// Readers authenticated by the AuthHandler only get the objects of namespaces they can list.
+// The metrics can be filtered by query parameters, see filterFromQuery.
+// The response is streamed gzip encoded, if the scraper accepts it.
 // nolint: errcheck
 func (m *ManagedMetricsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
-
-	totalCount := 0
-	for _, c := range m.callbacks {
-		_, count := c()
-		totalCount += count
+	stores, ok := m.route(r)
+	if !ok {
+		http.NotFound(writer, r)
+		return
 	}
-	for _, w := range m.metricsWriter {
-		w.WriteAll(writer)
+	start := time.Now()
+	counter := &countingWriter{ResponseWriter: writer}
+	filter := filterFromQuery(r)
+	if r != nil {
+		if reader, ok := readerFromContext(r.Context()); ok {
+			filter.authorize = func(gvr schema.GroupVersionResource, namespaces []string) []string {
+				return reader.authorizedNamespaces(r.Context(), gvr, namespaces)
+			}
+		}
+	}
+	if stores != nil {
+		filter.stores = make(map[string]struct{}, len(stores))
+		for _, name := range stores {
+			filter.stores[name] = struct{}{}
+		}
 	}
 
-	writer.Write([]byte("# TYPE x_metric_resources_count_total gauge\n# HELP x_metric_resources_count_total A metric to count all resources\n"))
-	writer.Write([]byte("x_metric_resources_count_total "))
-	writer.Write([]byte(strconv.Itoa(totalCount)))
-	writer.Write([]byte{'\n'})
+	format := negotiateFormat(r)
+	writer.Header().Set("Content-Type", string(format))
+	w, flush := compressedWriter(counter, r)
+
+	totalCount := m.registry.WriteAll(w, format, filter)
+
+	w.Write([]byte("# TYPE x_metric_resources_count_total gauge\n# HELP x_metric_resources_count_total A metric to count all resources\n"))
+	w.Write([]byte("x_metric_resources_count_total "))
+	w.Write([]byte(strconv.Itoa(totalCount)))
+	w.Write([]byte{'\n'})
+	if format == expfmt.FmtOpenMetrics {
+		w.Write([]byte("# EOF\n"))
+	}
+	flush()
+	scrapeDuration.Observe(time.Since(start).Seconds())
+	scrapeSize.Observe(float64(counter.written))
 
 	if closer, ok := writer.(io.Closer); ok {
 		closer.Close()
 	}
 }
 
-func (m *ManagedMetricsHandler) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) chan struct{} {
-	reflectorStore, channel := m.registerMetricStoreForGVR(ctx, metricName, gvr, namespace)
+// RegisterAndAddMetricStoreForGVR registers a metric store for the objects of the gvr and starts watching them.
+// The metric name is the name of the store and of its series, a non-empty namespace restricts the store to the objects of the namespace.
+func (m *ManagedMetricsHandler) RegisterAndAddMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts StoreOptions) chan struct{} {
+	reflectorStore, channel := m.registerMetricStoreForGVR(ctx, metricName, gvr, namespace, opts)
 	m.addMetricStore(metricName, reflectorStore)
 	return channel
 }
 
+// MigrateMetricStore moves an existing metric store to another version of its resource.
+// The objects of the store are updated by the initial events of the informer of the new version, so the metric series are kept.
+// Once the informer synced, the objects of the store are replaced with its objects, which drops objects deleted during the migration.
+// The store has to be stopped watching the old version by the caller.
+func (m *ManagedMetricsHandler) MigrateMetricStore(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts StoreOptions) chan struct{} {
+	metricStore, ok := m.registry.Get(metricName)
+	if !ok {
+		return m.RegisterAndAddMetricStoreForGVR(ctx, metricName, gvr, namespace, opts)
+	}
+	metricStore.SetGVR(gvr)
+	return m.runInformer(ctx, metricStore, gvr, namespace, opts, true)
+}
+
 func (m *ManagedMetricsHandler) addMetricStore(name string, metricStore store.IXMetricsStore) {
-	m.metricsWriter[name] = metricStore
+	m.registry.Add(name, metricStore)
 }
 
 func (m *ManagedMetricsHandler) RemoveMetricStore(name string) {
-	metricsStore := m.metricsWriter[name]
-	callbackUid := metricsStore.GetCallbacUid()
-	delete(m.callbacks, callbackUid)
-	delete(m.metricsWriter, name)
+	m.registry.Remove(name)
 }
 
-func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string) (store.IXMetricsStore, chan struct{}) {
-
-	log := log.FromContext(ctx)
+func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, metricName string, gvr schema.GroupVersionResource, namespace string, opts StoreOptions) (store.IXMetricsStore, chan struct{}) {
 
-	if namespace != "" {
-		metricName = GetValidLabel(namespace + "_" + metricName)
+	headers := []store.FamilyHeader{
+		newFamilyHeader(metricName, string(metric.Gauge), "", "A metrics series for each object"),
+		newFamilyHeader(metricName+"_created", string(metric.Gauge), "", "Unix creation timestamp"),
+		newFamilyHeader(metricName+"_labels", string(metric.Gauge), "", "Labels from the kubernetes object"),
+		newFamilyHeader(metricName+"_info", string(metric.Gauge), "", "A metrics series exposing parameters as labels"),
 	}
-	headers := []string{
-		"# TYPE %s gauge\n# HELP %s A metrics series for each object",
-		"# TYPE %s_created gauge\n# HELP %s_created Unix creation timestamp",
-		"# TYPE %s_labels gauge\n# HELP %s_labels Labels from the kubernetes object",
-		"# TYPE %s_info gauge\n# HELP %s_info A metrics series exposing parameters as labels",
-		"# TYPE %s_ready gauge\n# HELP %s_ready A metrics series mapping the Ready status condition to a value (True=1,False=0,other=-1)",
-		"# TYPE %s_ready_time gauge\n# HELP %s_ready_time Unix timestamp of last ready change",
-		"# TYPE %s_synced gauge\n# HELP %s_synced A metrics series mapping the Synced status condition to a value (True=1,False=0,other=-1)",
-		"# TYPE %s_synced_time gauge\n# HELP %s_synced_time Unix timestamp of last synced change",
-	}
-	for i, hfmt := range headers {
-		headers[i] = fmt.Sprintf(hfmt, metricName, metricName)
+	if !opts.Metadata {
+		headers = append(headers, statusHeaders(metricName, opts)...)
 	}
 	labelKeys := []string{"name"}
 	labelValues := func(obj *unstructured.Unstructured) []string {
 		return []string{obj.GetName()}
 	}
 
-	if namespace != "" {
+	if namespace != "" || opts.NamespaceFilter {
 		labelKeys = append(labelKeys, "namespace")
 		labelValues = func(obj *unstructured.Unstructured) []string {
 			return []string{obj.GetName(), obj.GetNamespace()}
 		}
 	}
 	reflectorStore := m.newStoreHandler(headers, func(objAny any) []metric.FamilyInterface {
-		obj := objAny.(*unstructured.Unstructured)
+		obj, ok := objAny.(*unstructured.Unstructured)
+		if !ok {
+			obj = metadataToUnstructured(objAny)
+		}
 		paved := fieldpath.Pave(obj.Object)
 		o := metric.Family{
 			Name: metricName,
@@ -196,13 +441,10 @@ func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, m
 		}
 		families = append(families, &labels)
 
-		mappings := []InfoMappings{}
-
 		var infoKeys, infoValues []string
-		for _, m := range mappings {
-			val, _ := paved.GetString(m.FieldPath)
-			infoKeys = append(infoKeys, m.Label)
-			infoValues = append(infoValues, val)
+		for _, m := range opts.InfoMappings {
+			infoKeys = append(infoKeys, GetValidLabel(m.Label))
+			infoValues = append(infoValues, getFieldString(paved, m.FieldPath))
 		}
 
 		o_info := metric.Family{
@@ -217,14 +459,17 @@ func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, m
 		}
 
 		families = append(families, &o_info)
+		if opts.Metadata {
+			return families
+		}
 
 		status := getCrossplaneStatus(obj)
 		o_ready := metric.Family{
 			Name: metricName + "_ready",
 			Metrics: []*metric.Metric{
 				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
+					LabelKeys:   append(labelKeys, "reason"),
+					LabelValues: append(labelValues(obj), status.readyReason),
 					Value:       status.ready,
 				},
 			},
@@ -249,8 +494,8 @@ func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, m
 			Name: metricName + "_synced",
 			Metrics: []*metric.Metric{
 				{
-					LabelKeys:   labelKeys,
-					LabelValues: labelValues(obj),
+					LabelKeys:   append(labelKeys, "reason"),
+					LabelValues: append(labelValues(obj), status.syncedReason),
 					Value:       status.synced,
 				},
 			},
@@ -271,29 +516,282 @@ func (m *ManagedMetricsHandler) registerMetricStoreForGVR(ctx context.Context, m
 
 		families = append(families, o_synced_time)
 
+		o_condition := metric.Family{
+			Name: metricName + "_condition",
+		}
+		o_condition_stateset := metric.Family{
+			Name: metricName + "_condition",
+		}
+		o_condition_time := metric.Family{
+			Name: metricName + "_condition_last_transition_time",
+		}
+		for _, c := range getConditions(obj, opts.ConditionTypes) {
+			o_condition.Metrics = append(o_condition.Metrics, &metric.Metric{
+				LabelKeys:   append(labelKeys, "type", "status", "reason"),
+				LabelValues: append(labelValues(obj), string(c.Type), string(c.Status), string(c.Reason)),
+				Value:       1,
+			})
+			conditionStatus := c.Status
+			if conditionStatus != corev1.ConditionTrue && conditionStatus != corev1.ConditionFalse {
+				conditionStatus = corev1.ConditionUnknown
+			}
+			for _, state := range []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown} {
+				var value float64
+				if state == conditionStatus {
+					value = 1
+				}
+				o_condition_stateset.Metrics = append(o_condition_stateset.Metrics, &metric.Metric{
+					LabelKeys:   append(labelKeys, "type", "reason", metricName+"_condition"),
+					LabelValues: append(labelValues(obj), string(c.Type), string(c.Reason), string(state)),
+					Value:       value,
+				})
+			}
+			o_condition_time.Metrics = append(o_condition_time.Metrics, &metric.Metric{
+				LabelKeys:   append(labelKeys, "type"),
+				LabelValues: append(labelValues(obj), string(c.Type)),
+				Value:       float64(c.LastTransitionTime.Unix()),
+			})
+		}
+
+		families = append(families, &o_condition, &o_condition_stateset, &o_condition_time)
+
+		if opts.ReadyMessageLength > 0 {
+			o_ready_info := metric.Family{
+				Name: metricName + "_ready_info",
+				Metrics: []*metric.Metric{
+					{
+						LabelKeys:   append(labelKeys, "reason", "message"),
+						LabelValues: append(labelValues(obj), status.readyReason, truncate(status.readyMessage, opts.ReadyMessageLength)),
+						Value:       1,
+					},
+				},
+			}
+			families = append(families, &o_ready_info)
+		}
+
+		for _, v := range opts.ValueMappings {
+			name := metricName + "_" + valueMetricName(v)
+			o_value := metric.Family{
+				Name: name,
+			}
+			o_value_created := metric.Family{
+				Name: strings.TrimSuffix(name, "_total") + "_created",
+			}
+			if value, ok := getFieldValue(paved, v.FieldPath); ok {
+				var valueKeys, valueValues []string
+				for _, l := range v.Labels {
+					valueKeys = append(valueKeys, GetValidLabel(l.Label))
+					valueValues = append(valueValues, getFieldString(paved, l.FieldPath))
+				}
+				o_value.Metrics = []*metric.Metric{
+					{
+						LabelKeys:   append(labelKeys, valueKeys...),
+						LabelValues: append(labelValues(obj), valueValues...),
+						Value:       value,
+					},
+				}
+				o_value_created.Metrics = []*metric.Metric{
+					{
+						LabelKeys:   o_value.Metrics[0].LabelKeys,
+						LabelValues: o_value.Metrics[0].LabelValues,
+						Value:       float64(obj.GetCreationTimestamp().Unix()),
+					},
+				}
+			}
+			// the families are added even without a value to keep them aligned with the headers
+			families = append(families, &o_value)
+			if isOpenMetricsCounter(v) {
+				// counters in OpenMetrics are created with the object
+				families = append(families, familyList{&o_value, &o_value_created})
+			}
+		}
+
 		return families
 	}, ctx, m.Client, namespace, gvr, metricName)
-	callbackUid, countCallBack := reflectorStore.GetCallback()
-	m.callbacks[callbackUid] = countCallBack
-	lw := cache.ListWatch{
-		ListFunc: func(opt metav1.ListOptions) (runtime.Object, error) {
-			o, err := m.Client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				log.Info("err listing")
-			}
-			return o, err
+	return reflectorStore, m.runInformer(ctx, reflectorStore, gvr, namespace, opts, false)
+}
+
+// statusHeaders returns the headers of the families based on the status and the value mappings of the objects, which are not available in metadata only stores
+func statusHeaders(metricName string, opts StoreOptions) []store.FamilyHeader {
+	headers := []store.FamilyHeader{
+		newFamilyHeader(metricName+"_ready", string(metric.Gauge), "", "A metrics series mapping the Ready status condition to a value (True=1,False=0,other=-1)"),
+		newFamilyHeader(metricName+"_ready_time", string(metric.Gauge), "", "Unix timestamp of last ready change"),
+		newFamilyHeader(metricName+"_synced", string(metric.Gauge), "", "A metrics series mapping the Synced status condition to a value (True=1,False=0,other=-1)"),
+		newFamilyHeader(metricName+"_synced_time", string(metric.Gauge), "", "Unix timestamp of last synced change"),
+		// conditions are a stateset in OpenMetrics, with a series for each possible status
+		{Name: metricName + "_condition", Text: textHeader(metricName+"_condition", string(metric.Gauge), "A metrics series for each status condition of the object")},
+		{Name: metricName + "_condition", OpenMetrics: openMetricsHeader(metricName+"_condition", typeStateSet, "", "A metrics series for each status of each status condition of the object")},
+		newFamilyHeader(metricName+"_condition_last_transition_time", string(metric.Gauge), "", "Unix timestamp of the last transition of each status condition"),
+	}
+	if opts.ReadyMessageLength > 0 {
+		headers = append(headers, newFamilyHeader(metricName+"_ready_info", string(metric.Gauge), "", "A metrics series exposing reason and message of the Ready status condition as labels"))
+	}
+	for _, v := range opts.ValueMappings {
+		help := v.Help
+		if help == "" {
+			help = "A metrics series exposing the value of " + v.FieldPath
+		}
+		name := metricName + "_" + valueMetricName(v)
+		switch {
+		case isOpenMetricsCounter(v):
+			// the family of a counter is named without the _total suffix in OpenMetrics
+			headers = append(headers,
+				store.FamilyHeader{Name: name, Text: textHeader(name, v.Type, help)},
+				store.FamilyHeader{Name: name, OpenMetrics: openMetricsHeader(strings.TrimSuffix(name, "_total"), v.Type, v.Unit, help)},
+			)
+		case v.Type == string(metric.Counter):
+			headers = append(headers, store.FamilyHeader{
+				Name:        name,
+				Text:        textHeader(name, v.Type, help),
+				OpenMetrics: openMetricsHeader(name, typeUnknown, v.Unit, help),
+			})
+		default:
+			headers = append(headers, newFamilyHeader(name, string(metric.Gauge), v.Unit, help))
+		}
+	}
+	return headers
+}
+
+// runInformer passes the objects of the gvr in the namespace to the metric store until the returned channel is closed.
+// The objects are watched by an informer shared with all stores of the gvr, only the metadata of the objects is watched for metadata only stores.
+func (m *ManagedMetricsHandler) runInformer(ctx context.Context, metricStore store.IXMetricsStore, gvr schema.GroupVersionResource, namespace string, opts StoreOptions, replace bool) chan struct{} {
+	log := log.FromContext(ctx).WithValues("resource", gvr.String())
+	channel := make(chan struct{})
+
+	fieldSelector, err := ParseFieldSelector(opts.FieldSelector)
+	if err != nil {
+		log.Error(err, "invalid field selector")
+		return channel
+	}
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		log.Error(err, "invalid label selector")
+		return channel
+	}
+	// the informer watches the objects of all namespaces, the namespaces of the store are selected by its handler
+	var target cache.Store = metricStore
+	var namespaces *store.NamespaceFilterStore
+	switch {
+	case opts.NamespaceFilter:
+		namespaces = store.NewNamespaceFilterStore(target, opts.Namespaces)
+		target = namespaces
+	case namespace != "":
+		target = store.NewNamespaceFilterStore(target, []string{namespace})
+	}
+	handler := newStoreEventHandler(target, labelSelector, fieldSelector, log)
+
+	key := informerKey{
+		gvr:      gvr,
+		metadata: opts.Metadata && m.MetadataClient != nil,
+	}
+	// the informer caches the objects pruned to the fields the metrics of all its stores are generated from
+	var paths []string
+	if !key.metadata {
+		paths = prunePaths(opts)
+	}
+	resyncPeriod := m.ResyncPeriod
+	if opts.ResyncPeriod > 0 {
+		resyncPeriod = opts.ResyncPeriod
+	}
+	informer, err := m.informers.addHandler(key, func() (cache.ListerWatcher, runtime.Object) {
+		return m.newListWatch(key)
+	}, paths, m.WatchBackoff, handler, resyncPeriod)
+	if err != nil {
+		log.Error(err, "unable to watch objects")
+		return channel
+	}
+	untrack := m.syncs.track(metricStore, gvr, informer, namespaces)
+	go func() {
+		<-channel
+		untrack()
+		informer.remove()
+	}()
+	if replace {
+		go informer.replaceAfterSync()
+	}
+
+	return channel
+}
+
+// Collector returns a collector of the number of metric stores of the handler and the objects in them.
+// It is registered with the metrics of the manager, next to the self metrics of the package.
+func (m *ManagedMetricsHandler) Collector() prometheus.Collector {
+	return &storeCollector{registry: m.registry}
+}
+
+// WatchError returns the error of the last list or watch of the resource, nil if it succeeded or the resource is not watched
+func (m *ManagedMetricsHandler) WatchError(gvr schema.GroupVersionResource) error {
+	return m.informers.watchError(gvr)
+}
+
+// NotifyWatchState adds a func called with the resource, when its list and watch starts or stops failing
+func (m *ManagedMetricsHandler) NotifyWatchState(notify func(gvr schema.GroupVersionResource)) {
+	m.informers.addNotify(notify)
+}
+
+// newListWatch returns the ListerWatcher of all objects of the informer key and the type of the objects.
+// The lifetime of a shared informer is not bound to the context of a store, so list and watch use a background context.
+func (m *ManagedMetricsHandler) newListWatch(key informerKey) (cache.ListerWatcher, runtime.Object) {
+	ctx := context.Background()
+	if key.metadata {
+		resource := m.MetadataClient.Resource(key.gvr)
+		return &cache.ListWatch{
+			ListFunc: func(ops metav1.ListOptions) (runtime.Object, error) {
+				return m.listPages(ctx, ops, func(ops metav1.ListOptions) (runtime.Object, error) {
+					return resource.List(ctx, ops)
+				})
+			},
+			WatchFunc: func(ops metav1.ListOptions) (watch.Interface, error) {
+				return resource.Watch(ctx, ops)
+			},
+		}, &metav1.PartialObjectMetadata{}
+	}
+	resource := m.Client.Resource(key.gvr)
+	return &cache.ListWatch{
+		ListFunc: func(ops metav1.ListOptions) (runtime.Object, error) {
+			return m.listPages(ctx, ops, func(ops metav1.ListOptions) (runtime.Object, error) {
+				return resource.List(ctx, ops)
+			})
 		},
 		WatchFunc: func(ops metav1.ListOptions) (watch.Interface, error) {
-			return m.Client.Resource(gvr).Namespace(namespace).Watch(ctx, ops)
+			return resource.Watch(ctx, ops)
 		},
-	}
-
-	re := cache.NewReflector(&lw, &unstructured.Unstructured{}, reflectorStore, 0)
+	}, &unstructured.Unstructured{}
+}
 
-	channel := make(chan struct{})
-	go re.Run(channel)
+// listPages lists the objects in pages of ListPageSize.
+// The reflector does not page lists at a resource version, so the api server can serve them from its watch cache.
+// Without a watch cache these lists time out for resources with many objects, so they are paged and merged into one list here.
+// An expired continue token falls back to a full list.
+func (m *ManagedMetricsHandler) listPages(ctx context.Context, ops metav1.ListOptions, list func(metav1.ListOptions) (runtime.Object, error)) (runtime.Object, error) {
+	if m.ListPageSize <= 0 {
+		return list(ops)
+	}
+	if ops.Limit > 0 {
+		// a page requested by the reflector
+		ops.Limit = m.ListPageSize
+		return list(ops)
+	}
+	p := pager.New(pager.SimplePageFunc(list))
+	p.PageSize = m.ListPageSize
+	obj, _, err := p.List(ctx, ops)
+	return obj, err
+}
 
-	return reflectorStore, channel
+// metadataToUnstructured converts the metadata of an object to an unstructured object, which has no spec and status
+func metadataToUnstructured(obj any) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return u
+	}
+	u.SetNamespace(o.GetNamespace())
+	u.SetName(o.GetName())
+	u.SetUID(o.GetUID())
+	u.SetCreationTimestamp(o.GetCreationTimestamp())
+	u.SetLabels(o.GetLabels())
+	u.SetAnnotations(o.GetAnnotations())
+	return u
 }
 
 func GetValidLabel(name string) string {
@@ -316,6 +814,72 @@ func GetValidLabel(name string) string {
 	}, name)
 }
 
+// getFieldString returns the value of the field at the given path as string.
+// Missing fields result in an empty string, non-string values are formatted.
+func getFieldString(paved *fieldpath.Paved, path string) string {
+	val, err := paved.GetValue(path)
+	if err != nil || val == nil {
+		return ""
+	}
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// getFieldValue returns the value of the field at the given path as float.
+// Numbers, booleans, quantities and RFC3339 timestamps are supported.
+func getFieldValue(paved *fieldpath.Paved, path string) (float64, bool) {
+	val, err := paved.GetValue(path)
+	if err != nil {
+		return 0, false
+	}
+	switch v := val.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		return parseStringValue(v)
+	}
+	return 0, false
+}
+
+func parseStringValue(s string) (float64, bool) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	if q, err := resource.ParseQuantity(s); err == nil {
+		return q.AsApproximateFloat64(), true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return float64(t.Unix()), true
+	}
+	return 0, false
+}
+
+// truncate caps the string to the given number of runes
+func truncate(s string, length int) string {
+	r := []rune(s)
+	if len(r) <= length {
+		return s
+	}
+	return string(r[:length])
+}
+
 func statusToPrometheusValue(s xpv1.ConditionedStatus, typ xpv1.ConditionType) float64 {
 	switch s.GetCondition(typ).Status {
 	case "True":
@@ -327,14 +891,37 @@ func statusToPrometheusValue(s xpv1.ConditionedStatus, typ xpv1.ConditionType) f
 	}
 }
 
+// getConditions returns the status conditions of the object, restricted to the given types if any
+func getConditions(u *unstructured.Unstructured, types []string) []xpv1.Condition {
+	conditioned := xpv1.ConditionedStatus{}
+	_ = fieldpath.Pave(u.Object).GetValueInto("status", &conditioned)
+	if len(types) == 0 {
+		return conditioned.Conditions
+	}
+
+	conditions := []xpv1.Condition{}
+	for _, c := range conditioned.Conditions {
+		for _, t := range types {
+			if string(c.Type) == t {
+				conditions = append(conditions, c)
+				break
+			}
+		}
+	}
+	return conditions
+}
+
 func getCrossplaneStatus(u *unstructured.Unstructured) crossplaneStatus {
 	conditioned := xpv1.ConditionedStatus{}
 	_ = fieldpath.Pave(u.Object).GetValueInto("status", &conditioned)
 
 	return crossplaneStatus{
-		ready:      statusToPrometheusValue(conditioned, xpv1.TypeReady),
-		synced:     statusToPrometheusValue(conditioned, xpv1.TypeSynced),
-		readyTime:  conditioned.GetCondition(xpv1.TypeReady).LastTransitionTime.Time,
-		syncedTime: conditioned.GetCondition(xpv1.TypeSynced).LastTransitionTime.Time,
+		ready:        statusToPrometheusValue(conditioned, xpv1.TypeReady),
+		synced:       statusToPrometheusValue(conditioned, xpv1.TypeSynced),
+		readyTime:    conditioned.GetCondition(xpv1.TypeReady).LastTransitionTime.Time,
+		syncedTime:   conditioned.GetCondition(xpv1.TypeSynced).LastTransitionTime.Time,
+		readyReason:  string(conditioned.GetCondition(xpv1.TypeReady).Reason),
+		syncedReason: string(conditioned.GetCondition(xpv1.TypeSynced).Reason),
+		readyMessage: conditioned.GetCondition(xpv1.TypeReady).Message,
 	}
 }
@@ -3,17 +3,70 @@ package handler_test
 import (
 	"context"
 	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"github.com/crossplane-contrib/x-metrics/pkg/handler"
 	store_test "github.com/crossplane-contrib/x-metrics/pkg/handler/mock"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
 )
 
+var testGVR = schema.GroupVersionResource{
+	Group:    "test.cloud",
+	Version:  "v1",
+	Resource: "objects",
+}
+
+var testGVRv2 = schema.GroupVersionResource{
+	Group:    "test.cloud",
+	Version:  "v2",
+	Resource: "objects",
+}
+
+func newTestObject(name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: fields}
+	obj.SetAPIVersion("test.cloud/v1")
+	obj.SetKind("Object")
+	obj.SetName(name)
+	return obj
+}
+
+func newFakeClient(objects ...runtime.Object) dynamic.Interface {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		testGVR:   "ObjectList",
+		testGVRv2: "ObjectList",
+	}, objects...)
+}
+
+// scrape registers a metric store for testGVR and returns the served metrics once all objects are synced
+func scrape(ctx context.Context, opts handler.StoreOptions, objects ...runtime.Object) string {
+	mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(objects...), store.NewXMetricsStore)
+	channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", opts)
+	DeferCleanup(func() {
+		close(channel)
+	})
+	var data string
+	Eventually(func() int {
+		w := store_test.ResponseWriterMock{}
+		mmHandler.ServeHTTP(&w, nil)
+		data = w.Data
+		return strings.Count(data, "\ntest_created{")
+	}).Should(Equal(len(objects)))
+	return data
+}
+
 var _ = Describe("Handler", func() {
 	Context("servehttp", func() {
 		It("Should write metric for total number of objects", func() {
@@ -45,18 +98,399 @@ var _ = Describe("Handler", func() {
 				cancel()
 			}()
 			dc, _ := dynamic.NewForConfig(cfg)
-			handler := handler.NewManagedMetricsHandlerWithStore(dc, store_test.NewXMetricsStoreMockGenerator(5, "Test"))
-			handler.RegisterAndAddMetricStoreForGVR(ctx, "test", schema.GroupVersionResource{
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(dc, store_test.NewXMetricsStoreMockGenerator(5, "Test"))
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", schema.GroupVersionResource{
 				Group:    "test",
 				Version:  "v1",
 				Resource: "object",
-			}, "")
+			}, "", handler.StoreOptions{})
 			w := store_test.ResponseWriterMock{}
-			handler.ServeHTTP(&w, nil)
+			mmHandler.ServeHTTP(&w, nil)
 
 			Expect(w.Data).Should(ContainSubstring("# TYPE x_metric_resources_count_total gauge"))
 			Expect(w.Data).Should(ContainSubstring("# HELP x_metric_resources_count_total A metric to count all resources"))
 			Expect(w.Data).Should(ContainSubstring("x_metric_resources_count_total 5"))
 		})
 	})
+	Context("info labels", func() {
+		It("Should expose mapped fields as labels of the info metric", func(ctx SpecContext) {
+			obj := newTestObject("a", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"forProvider": map[string]interface{}{
+						"region":    "eu-central-1",
+						"nodeCount": int64(3),
+					},
+				},
+			})
+			obj.SetAnnotations(map[string]string{"crossplane.io/external-name": "ext-a"})
+
+			data := scrape(ctx, handler.StoreOptions{
+				InfoMappings: []handler.InfoMappings{
+					{FieldPath: "spec.forProvider.region", Label: "region"},
+					{FieldPath: "spec.forProvider.nodeCount", Label: "node_count"},
+					{FieldPath: "metadata.annotations['crossplane.io/external-name']", Label: "external_name"},
+					{FieldPath: "spec.forProvider.missing", Label: "missing"},
+				},
+			}, obj)
+
+			Expect(data).Should(ContainSubstring(`test_info{name="a",region="eu-central-1",node_count="3",external_name="ext-a",missing=""} 1`))
+		})
+		It("Should merge info mappings of all consumers by label", func() {
+			merged := handler.MergeStoreOptions(
+				handler.StoreOptions{InfoMappings: []handler.InfoMappings{{FieldPath: "spec.b", Label: "b"}, {FieldPath: "spec.a", Label: "a"}}},
+				handler.StoreOptions{InfoMappings: []handler.InfoMappings{{FieldPath: "spec.other", Label: "a"}, {FieldPath: "spec.c", Label: "c"}}},
+			)
+
+			Expect(merged.InfoMappings).Should(Equal([]handler.InfoMappings{
+				{FieldPath: "spec.a", Label: "a"},
+				{FieldPath: "spec.b", Label: "b"},
+				{FieldPath: "spec.c", Label: "c"},
+			}))
+		})
+		It("Should reject reserved and duplicate labels", func() {
+			Expect(handler.ValidateInfoMappings([]handler.InfoMappings{{FieldPath: "spec.a", Label: "a"}, {FieldPath: "spec.b", Label: "b"}})).Should(Succeed())
+			Expect(handler.ValidateInfoMappings([]handler.InfoMappings{{FieldPath: "metadata.name", Label: "name"}})).Should(MatchError(`label "name" is reserved`))
+			Expect(handler.ValidateInfoMappings([]handler.InfoMappings{{FieldPath: "metadata.namespace", Label: "namespace"}})).Should(MatchError(`label "namespace" is reserved`))
+			Expect(handler.ValidateInfoMappings([]handler.InfoMappings{{FieldPath: "spec.a", Label: "a"}, {FieldPath: "spec.b", Label: "a"}})).Should(MatchError(`label "a" is mapped more than once`))
+		})
+	})
+	Context("value metrics", func() {
+		It("Should expose values parsed from fields", func(ctx SpecContext) {
+			obj := newTestObject("a", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"forProvider": map[string]interface{}{
+						"region":    "eu-central-1",
+						"nodeCount": int64(3),
+						"ratio":     0.5,
+						"enabled":   true,
+						"storage":   "10Gi",
+						"since":     "2023-01-02T03:04:05Z",
+						"invalid":   "not-a-number",
+					},
+				},
+			})
+
+			data := scrape(ctx, handler.StoreOptions{
+				ValueMappings: []handler.ValueMappings{
+					{Name: "node_count", FieldPath: "spec.forProvider.nodeCount", Labels: []handler.InfoMappings{{FieldPath: "spec.forProvider.region", Label: "region"}}},
+					{Name: "ratio", FieldPath: "spec.forProvider.ratio"},
+					{Name: "enabled", FieldPath: "spec.forProvider.enabled"},
+					{Name: "storage_bytes", FieldPath: "spec.forProvider.storage"},
+					{Name: "since", FieldPath: "spec.forProvider.since", Type: "counter", Help: "Timestamp of since"},
+					{Name: "invalid", FieldPath: "spec.forProvider.invalid"},
+					{Name: "missing", FieldPath: "spec.forProvider.missing"},
+				},
+			}, obj)
+
+			Expect(data).Should(ContainSubstring("# TYPE test_node_count gauge\n# HELP test_node_count A metrics series exposing the value of spec.forProvider.nodeCount\n"))
+			Expect(data).Should(ContainSubstring(`test_node_count{name="a",region="eu-central-1"} 3`))
+			Expect(data).Should(ContainSubstring(`test_ratio{name="a"} 0.5`))
+			Expect(data).Should(ContainSubstring(`test_enabled{name="a"} 1`))
+			Expect(data).Should(ContainSubstring(`test_storage_bytes{name="a"} 1.073741824e+10`))
+			Expect(data).Should(ContainSubstring("# TYPE test_since counter\n# HELP test_since Timestamp of since\n"))
+			Expect(data).Should(ContainSubstring(`test_since{name="a"} 1.672628645e+09`))
+			Expect(data).Should(ContainSubstring("# TYPE test_invalid gauge"))
+			Expect(data).ShouldNot(ContainSubstring(`test_invalid{`))
+			Expect(data).ShouldNot(ContainSubstring(`test_missing{`))
+		})
+		It("Should reject value metrics named like the metrics of every resource or like each other", func() {
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{
+				{Name: "nodes", FieldPath: "spec.nodes", Labels: []handler.InfoMappings{{FieldPath: "spec.region", Label: "region"}}},
+				{Name: "requests_total", FieldPath: "status.requests", Type: "counter"},
+				{Name: "storage", FieldPath: "spec.storage", Unit: "bytes"},
+			})).Should(Succeed())
+
+			for _, reserved := range []string{"info", "labels", "created", "ready", "synced", "condition", "ready_time", "resource_count"} {
+				Expect(handler.ValidateValueMappings([]handler.ValueMappings{{Name: reserved, FieldPath: "spec.a"}})).Should(MatchError(ContainSubstring("of every resource")), reserved)
+			}
+			// the unit is appended to the name, counters are exposed as a family without _total and a _created series in OpenMetrics
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{{Name: "ready", FieldPath: "spec.a", Unit: "time"}})).Should(MatchError(ContainSubstring(`"ready_time"`)))
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{{Name: "labels_total", FieldPath: "spec.a", Type: "counter"}})).Should(MatchError(ContainSubstring(`"labels"`)))
+
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{
+				{Name: "nodes", FieldPath: "spec.a"},
+				{Name: "nodes", FieldPath: "spec.b"},
+			})).Should(MatchError(`value metric "nodes" is named like value metric "nodes"`))
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{
+				{Name: "requests_total", FieldPath: "spec.a", Type: "counter"},
+				{Name: "requests_created", FieldPath: "spec.b"},
+			})).Should(MatchError(`value metric "requests_created" is named like value metric "requests_total"`))
+		})
+		It("Should reject reserved and duplicate value labels", func() {
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{
+				{Name: "nodes", FieldPath: "spec.nodes", Labels: []handler.InfoMappings{{FieldPath: "spec.namespace", Label: "namespace"}}},
+			})).Should(MatchError(`value metric "nodes": label "namespace" is reserved`))
+			Expect(handler.ValidateValueMappings([]handler.ValueMappings{
+				{Name: "nodes", FieldPath: "spec.nodes", Labels: []handler.InfoMappings{{FieldPath: "spec.a", Label: "a"}, {FieldPath: "spec.b", Label: "a"}}},
+			})).Should(MatchError(`value metric "nodes": label "a" is mapped more than once`))
+		})
+	})
+	Context("condition metrics", func() {
+		conditions := map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":               "Ready",
+						"status":             "False",
+						"reason":             "Creating",
+						"lastTransitionTime": "2023-01-02T03:04:05Z",
+					},
+					map[string]interface{}{
+						"type":               "Healthy",
+						"status":             "True",
+						"reason":             "Available",
+						"lastTransitionTime": "2023-01-02T03:04:06Z",
+					},
+				},
+			},
+		}
+		It("Should expose all conditions", func(ctx SpecContext) {
+			data := scrape(ctx, handler.StoreOptions{}, newTestObject("a", conditions))
+
+			Expect(data).Should(ContainSubstring(`test_condition{name="a",type="Ready",status="False",reason="Creating"} 1`))
+			Expect(data).Should(ContainSubstring(`test_condition{name="a",type="Healthy",status="True",reason="Available"} 1`))
+			Expect(data).Should(ContainSubstring(`test_condition_last_transition_time{name="a",type="Ready"} 1.672628645e+09`))
+			Expect(data).Should(ContainSubstring(`test_condition_last_transition_time{name="a",type="Healthy"} 1.672628646e+09`))
+		})
+		It("Should expose only allowed conditions", func(ctx SpecContext) {
+			data := scrape(ctx, handler.StoreOptions{ConditionTypes: []string{"Healthy"}}, newTestObject("a", conditions))
+
+			Expect(data).ShouldNot(ContainSubstring(`test_condition{name="a",type="Ready"`))
+			Expect(data).Should(ContainSubstring(`test_condition{name="a",type="Healthy",status="True",reason="Available"} 1`))
+		})
+		It("Should expose the reason of ready and synced", func(ctx SpecContext) {
+			data := scrape(ctx, handler.StoreOptions{}, newTestObject("a", conditions))
+
+			Expect(data).Should(ContainSubstring(`test_ready{name="a",reason="Creating"} 0`))
+			Expect(data).Should(ContainSubstring(`test_synced{name="a",reason=""} -1`))
+			Expect(data).ShouldNot(ContainSubstring("test_ready_info"))
+		})
+		It("Should expose the truncated ready message", func(ctx SpecContext) {
+			obj := newTestObject("a", map[string]interface{}{
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":               "Ready",
+							"status":             "False",
+							"reason":             "ReconcileError",
+							"message":            "cannot create instance: access denied",
+							"lastTransitionTime": "2023-01-02T03:04:05Z",
+						},
+					},
+				},
+			})
+			data := scrape(ctx, handler.StoreOptions{ReadyMessageLength: 22}, obj)
+
+			Expect(data).Should(ContainSubstring("# TYPE test_ready_info gauge\n"))
+			Expect(data).Should(ContainSubstring(`test_ready_info{name="a",reason="ReconcileError",message="cannot create instance"} 1`))
+		})
+		It("Should expose all conditions if a consumer has no allowlist", func() {
+			Expect(handler.MergeStoreOptions(
+				handler.StoreOptions{ConditionTypes: []string{"Healthy"}},
+				handler.StoreOptions{},
+			).ConditionTypes).Should(BeEmpty())
+			Expect(handler.MergeStoreOptions(
+				handler.StoreOptions{ConditionTypes: []string{"Healthy"}},
+				handler.StoreOptions{ConditionTypes: []string{"Responsive", "Healthy"}},
+			).ConditionTypes).Should(Equal([]string{"Healthy", "Responsive"}))
+		})
+	})
+	Context("selectors", func() {
+		It("Should only watch objects matching the label selector", func(ctx SpecContext) {
+			selected := newTestObject("a", map[string]interface{}{})
+			selected.SetLabels(map[string]string{"team": "payments"})
+			other := newTestObject("b", map[string]interface{}{})
+			other.SetLabels(map[string]string{"team": "search"})
+
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(selected, other), store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{
+				LabelSelector: "team=payments",
+			})
+			defer close(channel)
+
+			Eventually(func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				return w.Data
+			}).Should(ContainSubstring(`test_created{name="a"}`))
+
+			w := store_test.ResponseWriterMock{}
+			mmHandler.ServeHTTP(&w, nil)
+			Expect(w.Data).ShouldNot(ContainSubstring(`test_created{name="b"}`))
+		})
+		It("Should only expose objects of the filtered namespaces", func(ctx SpecContext) {
+			selected := newTestObject("a", map[string]interface{}{})
+			selected.SetNamespace("tenant-a")
+			other := newTestObject("b", map[string]interface{}{})
+			other.SetNamespace("tenant-b")
+
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(selected, other), store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{
+				NamespaceFilter: true,
+				Namespaces:      []string{"tenant-a"},
+			})
+			defer close(channel)
+
+			Eventually(func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				return w.Data
+			}).Should(ContainSubstring(`test_created{name="a",namespace="tenant-a"}`))
+
+			w := store_test.ResponseWriterMock{}
+			mmHandler.ServeHTTP(&w, nil)
+			Expect(w.Data).ShouldNot(ContainSubstring(`test_created{name="b"`))
+		})
+		It("Should keep the series of namespaces still selected when the namespaces change", func(ctx SpecContext) {
+			var objects []runtime.Object
+			for name, namespace := range map[string]string{"a": "tenant-a", "b": "tenant-b", "c": "tenant-c"} {
+				obj := newTestObject(name, map[string]interface{}{})
+				obj.SetNamespace(namespace)
+				obj.SetUID(types.UID("uid-" + name))
+				objects = append(objects, obj)
+			}
+			client := newFakeClient(objects...).(*fake.FakeDynamicClient)
+
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{
+				NamespaceFilter: true,
+				Namespaces:      []string{"tenant-a", "tenant-b"},
+			})
+			defer close(channel)
+			serve := func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				return w.Data
+			}
+			Eventually(serve).Should(ContainSubstring(`test_created{name="b",namespace="tenant-b"}`))
+			Expect(serve()).Should(ContainSubstring(`test_created{name="a",namespace="tenant-a"}`))
+
+			Expect(mmHandler.UpdateNamespaces("test", []string{"tenant-a", "tenant-c"})).Should(BeTrue())
+			Eventually(func(g Gomega) {
+				data := serve()
+				// the series of the namespace selected before and after are never dropped
+				Expect(data).Should(ContainSubstring(`test_created{name="a",namespace="tenant-a"}`))
+				g.Expect(data).Should(ContainSubstring(`test_created{name="c",namespace="tenant-c"}`))
+				g.Expect(data).ShouldNot(ContainSubstring(`namespace="tenant-b"`))
+			}).Should(Succeed())
+
+			lists := 0
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "list" {
+					lists++
+				}
+			}
+			Expect(lists).Should(Equal(1))
+			Expect(mmHandler.UpdateNamespaces("missing", []string{"tenant-a"})).Should(BeFalse())
+		})
+		It("Should not update the namespaces of stores without namespace selection", func(ctx SpecContext) {
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(), store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "tenant-a", handler.StoreOptions{})
+			defer close(channel)
+
+			Expect(mmHandler.UpdateNamespaces("test", []string{"tenant-b"})).Should(BeFalse())
+		})
+	})
+	Context("migration", func() {
+		It("Should keep the metric store when migrating to another version", func(ctx SpecContext) {
+			objV1 := newTestObject("a", map[string]interface{}{})
+			objV1.SetUID("uid-a")
+			objV1.SetLabels(map[string]string{"version": "v1"})
+			objV2 := newTestObject("a", map[string]interface{}{})
+			objV2.SetAPIVersion("test.cloud/v2")
+			objV2.SetUID("uid-a")
+			objV2.SetLabels(map[string]string{"version": "v2"})
+
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(objV1, objV2), store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+			serve := func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				return w.Data
+			}
+			Eventually(serve).Should(ContainSubstring(`test_labels{name="a",label_version="v1"} 1`))
+
+			close(channel)
+			channel = mmHandler.MigrateMetricStore(ctx, "test", testGVRv2, "", handler.StoreOptions{})
+			defer close(channel)
+
+			Eventually(serve).Should(ContainSubstring(`test_labels{name="a",label_version="v2"} 1`))
+			Expect(strings.Count(serve(), "\ntest_created{")).Should(Equal(1))
+		})
+		It("Should move the store to the new version and drop objects deleted during the migration", func(ctx SpecContext) {
+			a := newTestObject("a", map[string]interface{}{})
+			a.SetUID("uid-a")
+			b := newTestObject("b", map[string]interface{}{})
+			b.SetUID("uid-b")
+			// b was deleted while the store moved, so only a is listed in v2
+			aV2 := a.DeepCopy()
+			aV2.SetAPIVersion("test.cloud/v2")
+
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(a, b, aV2), store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+			serve := func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				return w.Data
+			}
+			Eventually(serve).Should(HaveSuffix("x_metric_resources_count_total 2\n"))
+
+			close(channel)
+			channel = mmHandler.MigrateMetricStore(ctx, "test", testGVRv2, "", handler.StoreOptions{})
+			defer close(channel)
+
+			Eventually(serve).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+			Expect(serve()).ShouldNot(ContainSubstring(`test_created{name="b"}`))
+			Expect(testutil.CollectAndCompare(mmHandler.Collector(), strings.NewReader(`
+# HELP x_metric_store_objects Number of objects in a metric store
+# TYPE x_metric_store_objects gauge
+x_metric_store_objects{group="test.cloud",resource="objects",store="test",version="v2"} 1
+`), "x_metric_store_objects")).Should(Succeed())
+		})
+	})
+	Context("metadata mode", func() {
+		It("Should only expose metrics of the metadata of the objects", func(ctx SpecContext) {
+			obj := &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{APIVersion: "test.cloud/v1", Kind: "Object"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "a",
+					UID:         "uid-a",
+					Labels:      map[string]string{"team": "payments"},
+					Annotations: map[string]string{"crossplane.io/external-name": "ext-a"},
+				},
+			}
+			scheme := runtime.NewScheme()
+			metav1.AddMetaToScheme(scheme) // nolint: errcheck
+
+			// the dynamic client has no objects, they are only listed with the metadata client
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(), store.NewXMetricsStore)
+			mmHandler.MetadataClient = metadatafake.NewSimpleMetadataClient(scheme, obj)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{
+				Metadata:      true,
+				InfoMappings:  []handler.InfoMappings{{FieldPath: "metadata.annotations['crossplane.io/external-name']", Label: "external_name"}},
+				ValueMappings: []handler.ValueMappings{{Name: "nodes", FieldPath: "spec.forProvider.nodeCount"}},
+			})
+			defer close(channel)
+
+			var data string
+			Eventually(func() string {
+				w := store_test.ResponseWriterMock{}
+				mmHandler.ServeHTTP(&w, nil)
+				data = w.Data
+				return data
+			}).Should(ContainSubstring(`test_labels{name="a",label_team="payments"} 1`))
+
+			Expect(data).Should(ContainSubstring(`test_created{name="a"} `))
+			Expect(data).Should(ContainSubstring(`test_info{name="a",external_name="ext-a"} 1`))
+			Expect(data).ShouldNot(ContainSubstring("test_ready"))
+			Expect(data).ShouldNot(ContainSubstring("test_condition"))
+			Expect(data).ShouldNot(ContainSubstring("test_nodes"))
+			Expect(data).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+		})
+		It("Should only watch metadata, if no consumer needs the full objects", func() {
+			Expect(handler.MergeStoreOptions(handler.StoreOptions{Metadata: true}, handler.StoreOptions{Metadata: true}).Metadata).Should(BeTrue())
+			Expect(handler.MergeStoreOptions(handler.StoreOptions{Metadata: true}, handler.StoreOptions{}).Metadata).Should(BeFalse())
+			Expect(handler.MergeStoreOptions().Metadata).Should(BeFalse())
+		})
+	})
 })
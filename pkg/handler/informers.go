@@ -0,0 +1,441 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerFactory shares one informer between the metric stores watching the same resource.
+// An informer lists and watches all objects of its resource once, the handlers of the stores select the objects of their namespaces and selectors.
+type informerFactory struct {
+	mutex     sync.Mutex
+	informers map[informerKey]*sharedInformer
+	// notify is called when the list and watch of a resource starts or stops failing
+	notify []func(schema.GroupVersionResource)
+}
+
+// informerKey identifies a shared informer, metadata only stores share informers watching only the metadata
+type informerKey struct {
+	gvr      schema.GroupVersionResource
+	metadata bool
+}
+
+// resyncCheckPeriod is how often an informer checks whether its handlers are due for a resync.
+// The resync period of a handler cannot be shorter, it is set when the handler is added to the running informer.
+const resyncCheckPeriod = time.Second
+
+type sharedInformer struct {
+	informer cache.SharedIndexInformer
+	stop     chan struct{}
+	watch    *watchState
+	// paths are the sorted field paths unstructured objects are pruned to, the union of the paths of all handlers added so far
+	paths    []string
+	handlers map[*informerHandler]cache.ResourceEventHandlerRegistration
+}
+
+// informerHandler is a handler added to a shared informer.
+// The informer of a handler is replaced, when a handler needing more paths is added to it.
+type informerHandler struct {
+	factory      *informerFactory
+	key          informerKey
+	handler      *storeEventHandler
+	resyncPeriod time.Duration
+	// shared is the current informer of the handler, guarded by the mutex of the factory
+	shared *sharedInformer
+}
+
+func newInformerFactory() *informerFactory {
+	return &informerFactory{
+		informers: map[informerKey]*sharedInformer{},
+	}
+}
+
+// addHandler adds the handler to the informer of the key. If there is none yet, the informer is created with the ListerWatcher
+// and the expected type returned by newListWatch and started. Unstructured objects are pruned to the paths before the informer caches them,
+// a running informer missing some of the paths is replaced by one pruning to the paths of all its handlers.
+// Failed lists and watches of the informer are retried with the backoff. The handler resyncs with the resync period, 0 disables resyncs.
+func (f *informerFactory) addHandler(key informerKey, newListWatch func() (cache.ListerWatcher, runtime.Object), paths []string, backoff wait.Backoff, handler *storeEventHandler, resyncPeriod time.Duration) (*informerHandler, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	shared, ok := f.informers[key]
+	switch {
+	case !ok:
+		var err error
+		if shared, err = f.newInformer(key, newListWatch, paths, backoff); err != nil {
+			return nil, err
+		}
+		go shared.informer.Run(shared.stop)
+		f.informers[key] = shared
+	case len(unionPaths(shared.paths, paths)) > len(shared.paths):
+		var err error
+		if shared, err = f.restart(key, shared, newListWatch, unionPaths(shared.paths, paths), backoff); err != nil {
+			return nil, err
+		}
+	}
+	h := &informerHandler{
+		factory:      f,
+		key:          key,
+		handler:      handler,
+		resyncPeriod: resyncPeriod,
+	}
+	if err := shared.add(h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// newInformer returns an informer of the key caching the objects pruned to the paths, which is not started yet
+func (f *informerFactory) newInformer(key informerKey, newListWatch func() (cache.ListerWatcher, runtime.Object), paths []string, backoff wait.Backoff) (*sharedInformer, error) {
+	lw, expectedType := newListWatch()
+	stop := make(chan struct{})
+	state := &watchState{
+		ListerWatcher: lw,
+		gvr:           key.gvr,
+		backoff:       backoff,
+		current:       backoff,
+		stop:          stop,
+		changed:       f.changed,
+	}
+	// metadata only objects have no fields to prune but their managed fields
+	transform := dropManagedFields
+	if !key.metadata {
+		transform = pruneFunc(paths)
+	}
+	informer := cache.NewSharedIndexInformer(state, expectedType, resyncCheckPeriod, cache.Indexers{})
+	if err := informer.SetTransform(transform); err != nil {
+		return nil, err
+	}
+	return &sharedInformer{
+		informer: informer,
+		stop:     stop,
+		watch:    state,
+		paths:    paths,
+		handlers: map[*informerHandler]cache.ResourceEventHandlerRegistration{},
+	}, nil
+}
+
+// restart replaces the running informer of the key by one pruning to the paths and moves its handlers to the new informer.
+// Events of the old informer may be missed meanwhile, so the stores of the handlers are replaced after the initial list of the new one.
+func (f *informerFactory) restart(key informerKey, old *sharedInformer, newListWatch func() (cache.ListerWatcher, runtime.Object), paths []string, backoff wait.Backoff) (*sharedInformer, error) {
+	shared, err := f.newInformer(key, newListWatch, paths, backoff)
+	if err != nil {
+		return nil, err
+	}
+	// the resource keeps its watch state until the new informer lists it
+	shared.watch.err = old.watch.error()
+	for h, registration := range old.handlers {
+		old.informer.RemoveEventHandler(registration) // nolint: errcheck
+		delete(old.handlers, h)
+		if err := shared.add(h); err != nil {
+			return nil, err
+		}
+		go h.replaceAfterSync()
+	}
+	close(old.stop)
+	go shared.informer.Run(shared.stop)
+	f.informers[key] = shared
+	return shared, nil
+}
+
+func (s *sharedInformer) add(h *informerHandler) error {
+	registration, err := s.informer.AddEventHandlerWithResyncPeriod(h.handler, h.resyncPeriod)
+	if err != nil {
+		return err
+	}
+	s.handlers[h] = registration
+	h.shared = s
+	return nil
+}
+
+func (h *informerHandler) current() *sharedInformer {
+	h.factory.mutex.Lock()
+	defer h.factory.mutex.Unlock()
+	return h.shared
+}
+
+// hasSynced returns whether the current informer of the handler completed its initial list
+func (h *informerHandler) hasSynced() bool {
+	shared := h.current()
+	return shared != nil && shared.informer.HasSynced()
+}
+
+// replaceAfterSync replaces the objects of the store with the objects cached by the current informer of the handler after its initial list.
+// Nothing is replaced if the handler was removed or moved to another informer meanwhile, which replaces the objects again.
+func (h *informerHandler) replaceAfterSync() {
+	shared := h.current()
+	if shared == nil || !cache.WaitForCacheSync(shared.stop, shared.informer.HasSynced) {
+		return
+	}
+	if h.current() == shared {
+		h.handler.replace(shared.informer)
+	}
+}
+
+// remove removes the handler from its informer, the informer is stopped after its last handler was removed
+func (h *informerHandler) remove() {
+	f := h.factory
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	shared := h.shared
+	if shared == nil {
+		return
+	}
+	shared.informer.RemoveEventHandler(shared.handlers[h]) // nolint: errcheck
+	delete(shared.handlers, h)
+	h.shared = nil
+	if len(shared.handlers) > 0 {
+		return
+	}
+	close(shared.stop)
+	delete(f.informers, h.key)
+	for other := range f.informers {
+		if other.gvr == h.key.gvr {
+			return
+		}
+	}
+	shared.watch.stopped()
+}
+
+// addNotify adds a func called with the resource, when its list and watch starts or stops failing
+func (f *informerFactory) addNotify(notify func(schema.GroupVersionResource)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.notify = append(f.notify, notify)
+}
+
+func (f *informerFactory) changed(gvr schema.GroupVersionResource) {
+	f.mutex.Lock()
+	notify := append([]func(schema.GroupVersionResource){}, f.notify...)
+	f.mutex.Unlock()
+	for _, n := range notify {
+		n(gvr)
+	}
+}
+
+// watchError returns the error of the last list or watch of the informers of the resource, nil if they succeeded
+func (f *informerFactory) watchError(gvr schema.GroupVersionResource) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for key, shared := range f.informers {
+		if key.gvr != gvr {
+			continue
+		}
+		if err := shared.watch.error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count returns the number of running informers
+func (f *informerFactory) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.informers)
+}
+
+// watchState wraps the ListerWatcher of an informer, it records failed lists and watches and backs off before retrying them.
+// The reflector of the informer has a backoff of its own, which is not configurable.
+type watchState struct {
+	cache.ListerWatcher
+	gvr     schema.GroupVersionResource
+	stop    <-chan struct{}
+	changed func(schema.GroupVersionResource)
+
+	mutex sync.Mutex
+	// backoff is the initial backoff, no delay is added if its duration is 0
+	backoff wait.Backoff
+	current wait.Backoff
+	delay   time.Duration
+	err     error
+}
+
+func (w *watchState) List(options metav1.ListOptions) (runtime.Object, error) {
+	w.wait()
+	obj, err := w.ListerWatcher.List(options)
+	w.record(err)
+	return obj, err
+}
+
+func (w *watchState) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w.wait()
+	watcher, err := w.ListerWatcher.Watch(options)
+	w.record(err)
+	return watcher, err
+}
+
+// wait blocks for the backoff delay after a failed attempt, until the informer is stopped
+func (w *watchState) wait() {
+	w.mutex.Lock()
+	delay := w.delay
+	w.mutex.Unlock()
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-w.stop:
+	}
+}
+
+// record updates the error and the backoff after an attempt, the factory is notified when the resource starts or stops failing
+func (w *watchState) record(err error) {
+	labelValues := []string{w.gvr.Group, w.gvr.Version, w.gvr.Resource}
+	w.mutex.Lock()
+	changed := (w.err == nil) != (err == nil)
+	w.err = err
+	if err != nil {
+		watchErrors.WithLabelValues(labelValues...).Inc()
+		if w.backoff.Duration > 0 {
+			w.delay = w.current.Step()
+		}
+	} else {
+		w.current = w.backoff
+		w.delay = 0
+		watchLastSuccess.WithLabelValues(labelValues...).SetToCurrentTime()
+	}
+	watchBackoff.WithLabelValues(labelValues...).Set(w.delay.Seconds())
+	w.mutex.Unlock()
+
+	if changed {
+		w.changed(w.gvr)
+	}
+}
+
+func (w *watchState) error() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// stopped removes the state of the resource from the metrics, after its last informer stopped
+func (w *watchState) stopped() {
+	watchBackoff.DeleteLabelValues(w.gvr.Group, w.gvr.Version, w.gvr.Resource)
+	watchLastSuccess.DeleteLabelValues(w.gvr.Group, w.gvr.Version, w.gvr.Resource)
+}
+
+// dropManagedFields removes the managed fields, which are never read by the stores, but often the largest part of an object
+func dropManagedFields(obj interface{}) (interface{}, error) {
+	if o, err := meta.Accessor(obj); err == nil {
+		o.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// storeEventHandler passes the events of the informer to a metric store.
+// The informer passes all objects of the resource, the handler selects the objects of the store by their labels and fields.
+type storeEventHandler struct {
+	// mutex orders the events and the replacement of all objects of the store
+	mutex  sync.Mutex
+	store  cache.Store
+	labels labels.Selector
+	fields fields.Selector
+	log    logr.Logger
+}
+
+func newStoreEventHandler(target cache.Store, labelSelector labels.Selector, fieldSelector fields.Selector, log logr.Logger) *storeEventHandler {
+	return &storeEventHandler{
+		store:  target,
+		labels: labelSelector,
+		fields: fieldSelector,
+		log:    log,
+	}
+}
+
+// selects returns whether the object matches the selectors of the store, field selectors are restricted to the name and the namespace
+func (h *storeEventHandler) selects(obj interface{}) bool {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return h.labels.Matches(labels.Set(o.GetLabels())) &&
+		h.fields.Matches(fields.Set{"metadata.name": o.GetName(), "metadata.namespace": o.GetNamespace()})
+}
+
+func (h *storeEventHandler) OnAdd(obj interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.selects(obj) {
+		return
+	}
+	if err := h.store.Add(obj); err != nil {
+		h.log.Error(err, "unable to add object to metric store")
+	}
+}
+
+// OnUpdate updates the object in the store, an object no longer matching the selectors is removed from it
+func (h *storeEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	switch {
+	case h.selects(newObj):
+		if err := h.store.Update(newObj); err != nil {
+			h.log.Error(err, "unable to update object in metric store")
+		}
+	case h.selects(oldObj):
+		if err := h.store.Delete(oldObj); err != nil {
+			h.log.Error(err, "unable to delete object from metric store")
+		}
+	}
+}
+
+// replace replaces the objects of the store with the selected objects cached by the informer.
+// The cache of the informer is updated before the events are passed to the handlers,
+// so events still passed afterwards do not change the objects replaced.
+func (h *storeEventHandler) replace(informer cache.SharedIndexInformer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var selected []interface{}
+	for _, obj := range informer.GetStore().List() {
+		if h.selects(obj) {
+			selected = append(selected, obj)
+		}
+	}
+	if err := h.store.Replace(selected, ""); err != nil {
+		h.log.Error(err, "unable to replace objects of metric store")
+	}
+}
+
+func (h *storeEventHandler) OnDelete(obj interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	if !h.selects(obj) {
+		return
+	}
+	if err := h.store.Delete(obj); err != nil {
+		h.log.Error(err, "unable to delete object from metric store")
+	}
+}
@@ -0,0 +1,215 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	store_test "github.com/crossplane-contrib/x-metrics/pkg/handler/mock"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+var _ = Describe("Shared informers", func() {
+	newObject := func(name string, namespace string, team string) *unstructured.Unstructured {
+		obj := newTestObject(name, map[string]interface{}{})
+		obj.SetNamespace(namespace)
+		obj.SetUID(types.UID("uid-" + name))
+		obj.SetLabels(map[string]string{"team": team})
+		return obj
+	}
+	serve := func(mmHandler *handler.ManagedMetricsHandler) string {
+		w := store_test.ResponseWriterMock{}
+		mmHandler.ServeHTTP(&w, nil)
+		return w.Data
+	}
+
+	It("Should list and watch all objects of a resource once for stores with different namespaces and selectors", func(ctx SpecContext) {
+		client := newFakeClient(
+			newObject("a", "ns1", "payments"),
+			newObject("b", "ns2", "payments"),
+			newObject("c", "ns1", "ops"),
+		).(*fake.FakeDynamicClient)
+
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+		channels := []chan struct{}{
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "all", testGVR, "", handler.StoreOptions{}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "namespaced", testGVR, "ns1", handler.StoreOptions{}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "payments", testGVR, "", handler.StoreOptions{LabelSelector: "team=payments"}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "named", testGVR, "ns1", handler.StoreOptions{FieldSelector: "metadata.name=c"}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "filtered", testGVR, "", handler.StoreOptions{NamespaceFilter: true, Namespaces: []string{"ns2"}}),
+		}
+		Eventually(func() string { return serve(&mmHandler) }).Should(HaveSuffix("x_metric_resources_count_total 9\n"))
+
+		created := regexp.MustCompile(`(?m)^(\w+)_created\{name="(\w)"`)
+		series := map[string][]string{}
+		for _, match := range created.FindAllStringSubmatch(serve(&mmHandler), -1) {
+			series[match[1]] = append(series[match[1]], match[2])
+		}
+		for _, names := range series {
+			sort.Strings(names)
+		}
+		Expect(series).Should(Equal(map[string][]string{
+			"all":        {"a", "b", "c"},
+			"namespaced": {"a", "c"},
+			"payments":   {"a", "b"},
+			"named":      {"c"},
+			"filtered":   {"b"},
+		}))
+		Expect(mmHandler.Informers()).Should(Equal(1))
+
+		verbs := map[string]int{}
+		for _, action := range client.Actions() {
+			verbs[action.GetVerb()]++
+			if list, ok := action.(k8stesting.ListAction); ok {
+				// all objects are listed, the handlers of the stores select theirs
+				Expect(list.GetNamespace()).Should(BeEmpty())
+				Expect(list.GetListRestrictions().Labels.Empty()).Should(BeTrue())
+				Expect(list.GetListRestrictions().Fields.Empty()).Should(BeTrue())
+			}
+		}
+		Expect(verbs).Should(Equal(map[string]int{"list": 1, "watch": 1}))
+
+		for _, channel := range channels {
+			close(channel)
+		}
+		Eventually(mmHandler.Informers).Should(Equal(0))
+	})
+	It("Should remove objects no longer matching the selectors of a store", func(ctx SpecContext) {
+		client := newFakeClient(newObject("a", "ns1", "payments"))
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+		channels := []chan struct{}{
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "payments", testGVR, "", handler.StoreOptions{LabelSelector: "team=payments"}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "ops", testGVR, "", handler.StoreOptions{LabelSelector: "team=ops"}),
+		}
+		defer func() {
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+		Eventually(func() string { return serve(&mmHandler) }).Should(ContainSubstring(`payments_created{name="a"} `))
+
+		_, err := client.Resource(testGVR).Namespace("ns1").Update(ctx, newObject("a", "ns1", "ops"), metav1.UpdateOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(func() string { return serve(&mmHandler) }).Should(ContainSubstring(`ops_created{name="a"} `))
+		Expect(serve(&mmHandler)).ShouldNot(ContainSubstring(`payments_created{`))
+	})
+})
+
+var _ = Describe("Field selectors", func() {
+	It("Should accept the name and the namespace", func() {
+		selector, err := handler.ParseFieldSelector("metadata.name=a,metadata.namespace!=b")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(selector.String()).Should(Equal("metadata.name=a,metadata.namespace!=b"))
+	})
+	It("Should reject other fields", func() {
+		_, err := handler.ParseFieldSelector("metadata.name=a,spec.size=10Gi")
+		Expect(err).Should(MatchError(ContainSubstring(`unsupported field "spec.size"`)))
+	})
+})
+
+var _ = Describe("Watch errors", func() {
+	It("Should report failing lists and back off until they succeed", func(ctx SpecContext) {
+		client := newFakeClient(newTestObject("a", map[string]interface{}{})).(*fake.FakeDynamicClient)
+		var failing atomic.Bool
+		failing.Store(true)
+		client.PrependReactor("list", "objects", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if failing.Load() {
+				return true, nil, apierrors.NewForbidden(testGVR.GroupResource(), "", errors.New("no access"))
+			}
+			return false, nil, nil
+		})
+		errorsBefore := gatheredValue("x_metric_watch_errors_total")
+
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+		mmHandler.WatchBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: math.MaxInt32, Cap: 50 * time.Millisecond}
+		notified := make(chan schema.GroupVersionResource, 10)
+		mmHandler.NotifyWatchState(func(gvr schema.GroupVersionResource) {
+			notified <- gvr
+		})
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+		defer close(channel)
+
+		Eventually(notified).Should(Receive(Equal(testGVR)))
+		Expect(mmHandler.WatchError(testGVR)).Should(MatchError(ContainSubstring("no access")))
+		Eventually(func() float64 { return gatheredValue("x_metric_watch_backoff_seconds") }).Should(BeNumerically(">", 0))
+		Expect(gatheredValue("x_metric_watch_errors_total")).Should(BeNumerically(">", errorsBefore))
+
+		failing.Store(false)
+		// the reflector retries after a backoff of its own of about a second
+		Eventually(notified).WithTimeout(5 * time.Second).Should(Receive(Equal(testGVR)))
+		Expect(mmHandler.WatchError(testGVR)).ShouldNot(HaveOccurred())
+		Eventually(func() float64 { return gatheredValue("x_metric_watch_backoff_seconds") }).Should(BeZero())
+		Expect(gatheredValue("x_metric_watch_last_success_timestamp_seconds")).Should(BeNumerically(">=", float64(time.Now().Add(-time.Minute).Unix())))
+		Eventually(func() string {
+			w := store_test.ResponseWriterMock{}
+			mmHandler.ServeHTTP(&w, nil)
+			return w.Data
+		}).Should(ContainSubstring(`test_created{name="a"} `))
+	})
+	It("Should not report resources without informers", func() {
+		mmHandler := handler.NewManagedMetricsHandler(newFakeClient())
+		Expect(mmHandler.WatchError(testGVR)).ShouldNot(HaveOccurred())
+	})
+})
+
+// updateCountingStore counts the updates of the objects of a metric store
+type updateCountingStore struct {
+	store.IXMetricsStore
+	updates *atomic.Int32
+}
+
+func (s updateCountingStore) Update(obj interface{}) error {
+	s.updates.Add(1)
+	return s.IXMetricsStore.Update(obj)
+}
+
+var _ = Describe("Resync periods", func() {
+	It("Should share the informer between stores with different resync periods", func(ctx SpecContext) {
+		updates := map[string]*atomic.Int32{}
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), func(headers []store.FamilyHeader, generate func(interface{}) []metric.FamilyInterface, ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource, name string) store.IXMetricsStore {
+			updates[name] = &atomic.Int32{}
+			return updateCountingStore{IXMetricsStore: store.NewXMetricsStore(headers, generate, ctx, client, namespace, gvr, name), updates: updates[name]}
+		})
+		mmHandler.ResyncPeriod = time.Hour
+		channels := []chan struct{}{
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "a", testGVR, "", handler.StoreOptions{}),
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "b", testGVR, "", handler.StoreOptions{ResyncPeriod: time.Second}),
+		}
+		defer func() {
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+
+		Expect(mmHandler.Informers()).Should(Equal(1))
+		Eventually(updates["b"].Load).WithTimeout(5 * time.Second).Should(BeNumerically(">=", 2))
+		Expect(updates["a"].Load()).Should(BeZero())
+	})
+	It("Should resync with the shortest period of all consumers", func() {
+		Expect(handler.MergeStoreOptions(
+			handler.StoreOptions{},
+			handler.StoreOptions{ResyncPeriod: time.Hour},
+			handler.StoreOptions{ResyncPeriod: time.Minute},
+		).ResyncPeriod).Should(Equal(time.Minute))
+		Expect(handler.MergeStoreOptions(handler.StoreOptions{}).ResyncPeriod).Should(BeZero())
+	})
+})
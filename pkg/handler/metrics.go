@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The metrics of x-metrics itself are served with the metrics of the manager
+var (
+	watchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "x_metric_watch_errors_total",
+		Help: "Number of failed lists and watches of a watched resource",
+	}, []string{"group", "version", "resource"})
+	watchBackoff = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metric_watch_backoff_seconds",
+		Help: "Delay before the next list or watch of a watched resource after failures, 0 if the last list or watch succeeded",
+	}, []string{"group", "version", "resource"})
+	watchLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "x_metric_watch_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful list or watch of a watched resource",
+	}, []string{"group", "version", "resource"})
+	scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "x_metric_scrape_duration_seconds",
+		Help:    "Duration of the scrapes of the x-metrics endpoint",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	})
+	scrapeSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "x_metric_scrape_response_size_bytes",
+		Help:    "Number of bytes written in the responses to scrapes of the x-metrics endpoint, after compression",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(watchErrors, watchBackoff, watchLastSuccess, scrapeDuration, scrapeSize)
+}
+
+var (
+	storesDesc = prometheus.NewDesc("x_metric_stores",
+		"Number of active metric stores", nil, nil)
+	storeObjectsDesc = prometheus.NewDesc("x_metric_store_objects",
+		"Number of objects in a metric store", []string{"store", "group", "version", "resource"}, nil)
+)
+
+// storeCollector collects the number of metric stores of a registry and the objects in them, when the metrics are gathered
+type storeCollector struct {
+	registry *MetricStoreRegistry
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storesDesc
+	ch <- storeObjectsDesc
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	// the stores are counted without holding the lock of the registry, like a scrape of the stores
+	c.registry.mutex.RLock()
+	entries := make(map[string]registryEntry, len(c.registry.entries))
+	for name, entry := range c.registry.entries {
+		entries[name] = entry
+	}
+	c.registry.mutex.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(storesDesc, prometheus.GaugeValue, float64(len(entries)))
+	for name, entry := range entries {
+		gvr, count := entry.callback()
+		ch <- prometheus.MustNewConstMetric(storeObjectsDesc, prometheus.GaugeValue, float64(count), name, gvr.Group, gvr.Version, gvr.Resource)
+	}
+}
+
+// countingWriter counts the bytes written to the response
+type countingWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+	return n, err
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	store_test "github.com/crossplane-contrib/x-metrics/pkg/handler/mock"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// gatheredValue returns the value of the self metric of the manager with the labels of testGVR
+func gatheredValue(name string) float64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).ShouldNot(HaveOccurred())
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["group"] == testGVR.Group && labels["version"] == testGVR.Version && labels["resource"] == testGVR.Resource {
+				if m.GetCounter() != nil {
+					return m.GetCounter().GetValue()
+				}
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return -1
+}
+
+// gatheredSamples returns the number of observations of a histogram of the manager without labels
+func gatheredSamples(name string) uint64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).ShouldNot(HaveOccurred())
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) == 1 {
+			return family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+var _ = Describe("Self metrics", func() {
+	It("Should collect the metric stores and their objects", func(ctx SpecContext) {
+		a := newTestObject("a", map[string]interface{}{})
+		a.SetUID("uid-a")
+		b := newTestObject("b", map[string]interface{}{})
+		b.SetUID("uid-b")
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(a, b), store.NewXMetricsStore)
+		collector := mmHandler.Collector()
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP x_metric_stores Number of active metric stores
+# TYPE x_metric_stores gauge
+x_metric_stores 0
+`))).Should(Succeed())
+
+		first := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "first", testGVR, "", handler.StoreOptions{})
+		defer close(first)
+		second := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "second", testGVR, "", handler.StoreOptions{LabelSelector: "app=none"})
+		defer close(second)
+
+		Eventually(func() error {
+			return testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP x_metric_store_objects Number of objects in a metric store
+# TYPE x_metric_store_objects gauge
+x_metric_store_objects{group="test.cloud",resource="objects",store="first",version="v1"} 2
+x_metric_store_objects{group="test.cloud",resource="objects",store="second",version="v1"} 0
+# HELP x_metric_stores Number of active metric stores
+# TYPE x_metric_stores gauge
+x_metric_stores 2
+`))
+		}).Should(Succeed())
+
+		mmHandler.RemoveMetricStore("second")
+		Expect(testutil.CollectAndCount(collector, "x_metric_store_objects")).Should(Equal(1))
+	})
+	It("Should observe the duration and size of scrapes", func(ctx SpecContext) {
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), store.NewXMetricsStore)
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+		defer close(channel)
+		durations := gatheredSamples("x_metric_scrape_duration_seconds")
+		sizes := gatheredSamples("x_metric_scrape_response_size_bytes")
+
+		w := store_test.ResponseWriterMock{}
+		mmHandler.ServeHTTP(&w, nil)
+
+		Expect(gatheredSamples("x_metric_scrape_duration_seconds")).Should(Equal(durations + 1))
+		Expect(gatheredSamples("x_metric_scrape_response_size_bytes")).Should(Equal(sizes + 1))
+	})
+})
@@ -7,20 +7,27 @@ import (
 
 	"github.com/crossplane-contrib/x-metrics/pkg/store"
 	"github.com/google/uuid"
+	"github.com/prometheus/common/expfmt"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
 )
 
 type XMetricsStoreMock struct {
+	GVR       schema.GroupVersionResource
 	Num       int
 	WriteData string
 	uid       string
 }
 
 // nolint: errcheck
-func (x *XMetricsStoreMock) WriteAll(w io.Writer) {
+func (x *XMetricsStoreMock) WriteAll(w io.Writer, _ expfmt.Format, _ store.Filter) int {
 	w.Write([]byte(x.WriteData))
+	return x.Num
+}
+
+func (x *XMetricsStoreMock) Namespaces() []string {
+	return nil
 }
 
 func (x *XMetricsStoreMock) GetCallback() (string, func() (schema.GroupVersionResource, int)) {
@@ -28,10 +35,14 @@ func (x *XMetricsStoreMock) GetCallback() (string, func() (schema.GroupVersionRe
 	uid := uuid.New().String()
 	x.uid = uid
 	return uid, func() (schema.GroupVersionResource, int) {
-		return schema.GroupVersionResource{}, x.Num
+		return x.GVR, x.Num
 	}
 }
 
+func (x *XMetricsStoreMock) SetGVR(gvr schema.GroupVersionResource) {
+	x.GVR = gvr
+}
+
 func (x *XMetricsStoreMock) GetCallbacUid() string {
 	return ""
 }
@@ -79,9 +90,9 @@ func (s *XMetricsStoreMock) Resync() error {
 	return nil
 }
 
-func NewXMetricsStoreMockGenerator(num int, data string) func([]string, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore {
+func NewXMetricsStoreMockGenerator(num int, data string) func([]store.FamilyHeader, func(interface{}) []metric.FamilyInterface, context.Context, dynamic.Interface, string, schema.GroupVersionResource, string) store.IXMetricsStore {
 
-	return func(headers []string, generateFunc func(interface{}) []metric.FamilyInterface, ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource, metricName string) store.IXMetricsStore {
+	return func(headers []store.FamilyHeader, generateFunc func(interface{}) []metric.FamilyInterface, ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource, metricName string) store.IXMetricsStore {
 
 		store := &XMetricsStoreMock{
 			Num:       num,
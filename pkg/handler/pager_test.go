@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+)
+
+var _ = Describe("Paginated lists", func() {
+	var requested []metav1.ListOptions
+	var expired bool
+	// list serves 5 objects in pages of the requested limit, the continue token is the index of the next object
+	list := func(ops metav1.ListOptions) (runtime.Object, error) {
+		requested = append(requested, ops)
+		start, _ := strconv.Atoi(ops.Continue)
+		if expired && start > 0 {
+			return nil, apierrors.NewResourceExpired("continue token expired")
+		}
+		end := 5
+		if ops.Limit > 0 && start+int(ops.Limit) < end {
+			end = start + int(ops.Limit)
+		}
+		result := &unstructured.UnstructuredList{}
+		for i := start; i < end; i++ {
+			result.Items = append(result.Items, *newTestObject("object-"+strconv.Itoa(i), map[string]interface{}{}))
+		}
+		if end < 5 {
+			result.SetContinue(strconv.Itoa(end))
+		}
+		return result, nil
+	}
+	names := func(obj runtime.Object) []string {
+		items, err := meta.ExtractList(obj)
+		Expect(err).ShouldNot(HaveOccurred())
+		var names []string
+		for _, item := range items {
+			o, err := meta.Accessor(item)
+			Expect(err).ShouldNot(HaveOccurred())
+			names = append(names, o.GetName())
+		}
+		return names
+	}
+	BeforeEach(func() {
+		requested = nil
+		expired = false
+	})
+
+	It("Should page lists not paged by the reflector", func(ctx SpecContext) {
+		h := handler.NewManagedMetricsHandler(newFakeClient())
+		h.ListPageSize = 2
+
+		obj, err := h.ListPages(ctx, metav1.ListOptions{ResourceVersion: "42"}, list)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names(obj)).Should(Equal([]string{"object-0", "object-1", "object-2", "object-3", "object-4"}))
+		Expect(requested).Should(HaveLen(3))
+		Expect(requested[0]).Should(Equal(metav1.ListOptions{ResourceVersion: "42", Limit: 2}))
+		Expect(requested[2].Continue).Should(Equal("4"))
+	})
+	It("Should request the configured page size for pages of the reflector", func(ctx SpecContext) {
+		h := handler.NewManagedMetricsHandler(newFakeClient())
+		h.ListPageSize = 2
+
+		obj, err := h.ListPages(ctx, metav1.ListOptions{ResourceVersion: "0", Limit: 500, TimeoutSeconds: new(int64)}, list)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names(obj)).Should(Equal([]string{"object-0", "object-1"}))
+		Expect(requested).Should(Equal([]metav1.ListOptions{{ResourceVersion: "0", Limit: 2, TimeoutSeconds: new(int64)}}))
+	})
+	It("Should keep the options of the reflector without a page size", func(ctx SpecContext) {
+		h := handler.NewManagedMetricsHandler(newFakeClient())
+
+		_, err := h.ListPages(ctx, metav1.ListOptions{ResourceVersion: "42"}, list)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requested).Should(Equal([]metav1.ListOptions{{ResourceVersion: "42"}}))
+	})
+	It("Should fall back to a full list, if the continue token expired", func(ctx SpecContext) {
+		h := handler.NewManagedMetricsHandler(newFakeClient())
+		h.ListPageSize = 2
+		expired = true
+
+		obj, err := h.ListPages(ctx, metav1.ListOptions{ResourceVersion: "42"}, list)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names(obj)).Should(HaveLen(5))
+		Expect(requested[len(requested)-1]).Should(Equal(metav1.ListOptions{ResourceVersion: "42"}))
+	})
+})
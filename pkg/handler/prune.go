@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// prunedPaths are the fields of an object read by the metric generator and the store, independent of the mappings
+var prunedPaths = []string{
+	"apiVersion",
+	"kind",
+	"metadata.name",
+	"metadata.namespace",
+	"metadata.uid",
+	"metadata.resourceVersion",
+	"metadata.creationTimestamp",
+	"metadata.labels",
+	"status.conditions",
+}
+
+// prunePaths returns the sorted field paths the metrics of the store are generated from:
+// the metadata, the status conditions and the field paths of the info and value mappings.
+func prunePaths(opts StoreOptions) []string {
+	paths := append([]string{}, prunedPaths...)
+	for _, m := range opts.InfoMappings {
+		paths = append(paths, m.FieldPath)
+	}
+	for _, v := range opts.ValueMappings {
+		paths = append(paths, v.FieldPath)
+		for _, l := range v.Labels {
+			paths = append(paths, l.FieldPath)
+		}
+	}
+	return uniquePaths(paths)
+}
+
+// unionPaths returns the sorted paths of both lists
+func unionPaths(a []string, b []string) []string {
+	return uniquePaths(append(append([]string{}, a...), b...))
+}
+
+func uniquePaths(paths []string) []string {
+	sort.Strings(paths)
+	unique := paths[:0]
+	for i, p := range paths {
+		if i == 0 || p != paths[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// pruneFunc returns the transform of an informer reducing unstructured objects to the field paths.
+// Everything else, like the spec and the managed fields, is dropped before the object is cached by the informer.
+func pruneFunc(paths []string) cache.TransformFunc {
+	var segments []fieldpath.Segments
+	for _, p := range paths {
+		// invalid paths are skipped, they have no value in the metrics either
+		if s, err := fieldpath.Parse(p); err == nil {
+			segments = append(segments, s)
+		}
+	}
+
+	return func(obj interface{}) (interface{}, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return obj, nil
+		}
+		pruned := map[string]interface{}{}
+		for _, s := range segments {
+			copyPath(pruned, u.Object, s)
+		}
+		return &unstructured.Unstructured{Object: pruned}, nil
+	}
+}
+
+// copyPath copies the value at the path from src into dst, creating the maps and arrays leading to it.
+// Values are shared with src, nothing is copied if the path does not exist in src.
+func copyPath(dst interface{}, src interface{}, s fieldpath.Segments) interface{} {
+	if len(s) == 0 {
+		return src
+	}
+	switch s[0].Type {
+	case fieldpath.SegmentField:
+		srcMap, ok := src.(map[string]interface{})
+		if !ok {
+			return dst
+		}
+		value, ok := srcMap[s[0].Field]
+		if !ok {
+			return dst
+		}
+		dstMap, ok := dst.(map[string]interface{})
+		if !ok {
+			dstMap = map[string]interface{}{}
+		}
+		dstMap[s[0].Field] = copyPath(dstMap[s[0].Field], value, s[1:])
+		return dstMap
+	case fieldpath.SegmentIndex:
+		srcArray, ok := src.([]interface{})
+		if !ok || int(s[0].Index) >= len(srcArray) {
+			return dst
+		}
+		dstArray, _ := dst.([]interface{})
+		if len(dstArray) < len(srcArray) {
+			// the array keeps its length, so the indexes of other paths still match
+			dstArray = append(dstArray, make([]interface{}, len(srcArray)-len(dstArray))...)
+		}
+		dstArray[s[0].Index] = copyPath(dstArray[s[0].Index], srcArray[s[0].Index], s[1:])
+		return dstArray
+	}
+	return dst
+}
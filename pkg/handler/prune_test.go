@@ -0,0 +1,223 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	store_test "github.com/crossplane-contrib/x-metrics/pkg/handler/mock"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// newManagedObject returns an object shaped like a managed resource with a large spec, managed fields and a last applied annotation
+func newManagedObject(name string) *unstructured.Unstructured {
+	forProvider := map[string]interface{}{
+		"region":    "eu-central-1",
+		"nodeCount": int64(3),
+	}
+	for i := 0; i < 100; i++ {
+		forProvider[fmt.Sprintf("parameter%d", i)] = map[string]interface{}{
+			"value":       strings.Repeat("x", 64),
+			"description": strings.Repeat("y", 128),
+		}
+	}
+	obj := newTestObject(name, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"forProvider": forProvider,
+			"providerConfigRef": map[string]interface{}{
+				"name": "default",
+			},
+		},
+		"status": map[string]interface{}{
+			"atProvider": map[string]interface{}{
+				"arn":    "arn:aws:rds:eu-central-1:123456789012:db:" + name,
+				"status": "available",
+			},
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True", "reason": "Available", "lastTransitionTime": "2023-01-01T00:00:00Z"},
+				map[string]interface{}{"type": "Synced", "status": "True", "reason": "ReconcileSuccess", "lastTransitionTime": "2023-01-01T00:00:00Z"},
+			},
+		},
+	})
+	obj.SetNamespace("default")
+	obj.SetUID(types.UID("uid-" + name))
+	obj.SetResourceVersion("42")
+	obj.SetLabels(map[string]string{"team": "payments"})
+	obj.SetAnnotations(map[string]string{
+		"crossplane.io/external-name":                      "ext-" + name,
+		"kubectl.kubernetes.io/last-applied-configuration": strings.Repeat("z", 16*1024),
+	})
+	managedFields, _, _ := unstructured.NestedFieldCopy(obj.Object, "spec")
+	obj.Object["metadata"].(map[string]interface{})["managedFields"] = []interface{}{
+		map[string]interface{}{"manager": "kubectl", "operation": "Update", "fieldsV1": managedFields},
+	}
+	return obj
+}
+
+var pruneOptions = handler.StoreOptions{
+	InfoMappings: []handler.InfoMappings{
+		{FieldPath: "spec.forProvider.region", Label: "region"},
+		{FieldPath: "metadata.annotations['crossplane.io/external-name']", Label: "external_name"},
+	},
+	ValueMappings: []handler.ValueMappings{
+		{Name: "nodes", FieldPath: "spec.forProvider.nodeCount", Labels: []handler.InfoMappings{{FieldPath: "status.atProvider.status", Label: "status"}}},
+	},
+}
+
+var _ = Describe("Object pruning", func() {
+	It("Should keep the metadata, the conditions and the mapped fields only", func() {
+		obj, err := handler.PruneFunc(pruneOptions)(newManagedObject("a"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		expected := newTestObject("a", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"forProvider": map[string]interface{}{"region": "eu-central-1", "nodeCount": int64(3)},
+			},
+			"status": map[string]interface{}{
+				"atProvider": map[string]interface{}{"status": "available"},
+				"conditions": newManagedObject("a").Object["status"].(map[string]interface{})["conditions"],
+			},
+		})
+		expected.SetNamespace("default")
+		expected.SetUID("uid-a")
+		expected.SetResourceVersion("42")
+		expected.SetLabels(map[string]string{"team": "payments"})
+		expected.SetAnnotations(map[string]string{"crossplane.io/external-name": "ext-a"})
+		Expect(obj).Should(Equal(expected))
+	})
+	It("Should keep the length of arrays for indexed field paths", func() {
+		obj := newTestObject("a", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"port": int64(80), "protocol": "tcp"},
+					map[string]interface{}{"port": int64(443), "protocol": "tcp"},
+				},
+			},
+		})
+		pruned, err := handler.PruneFunc(handler.StoreOptions{
+			InfoMappings: []handler.InfoMappings{{FieldPath: "spec.rules[1].port", Label: "port"}, {FieldPath: "spec.rules[5].port", Label: "missing"}},
+		})(obj)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		rules, _, _ := unstructured.NestedSlice(pruned.(*unstructured.Unstructured).Object, "spec", "rules")
+		Expect(rules).Should(Equal([]interface{}{nil, map[string]interface{}{"port": int64(443)}}))
+	})
+	It("Should cache the pruned objects in the informer", func(ctx SpecContext) {
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newManagedObject("a")), store.NewXMetricsStore)
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", pruneOptions)
+		defer close(channel)
+
+		Eventually(func() []interface{} { return mmHandler.CachedObjects(testGVR) }).Should(HaveLen(1))
+		expected, err := handler.PruneFunc(pruneOptions)(newManagedObject("a"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mmHandler.CachedObjects(testGVR)[0]).Should(Equal(expected))
+	})
+	It("Should prune the objects of an informer shared by stores with different mapped fields to the fields of all stores", func(ctx SpecContext) {
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newManagedObject("a")), store.NewXMetricsStore)
+		region := handler.StoreOptions{InfoMappings: pruneOptions.InfoMappings[:1]}
+		channels := []chan struct{}{
+			mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "a", testGVR, "", region),
+		}
+		defer func() {
+			for _, channel := range channels {
+				close(channel)
+			}
+		}()
+		Eventually(func() string { return serveData(&mmHandler) }).Should(ContainSubstring(`a_info{name="a",region="eu-central-1"} 1`))
+
+		channels = append(channels, mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "b", testGVR, "", pruneOptions))
+		expected, err := handler.PruneFunc(pruneOptions)(newManagedObject("a"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(func() []interface{} { return mmHandler.CachedObjects(testGVR) }).Should(Equal([]interface{}{expected}))
+		Expect(mmHandler.Informers()).Should(Equal(1))
+
+		Eventually(func() string { return serveData(&mmHandler) }).Should(ContainSubstring(`b_nodes{name="a",status="available"} 3`))
+		Expect(serveData(&mmHandler)).Should(ContainSubstring(`a_info{name="a",region="eu-central-1"} 1`))
+	})
+	It("Should expose the same metrics for pruned objects", func(ctx SpecContext) {
+		data := scrape(ctx, pruneOptions, newManagedObject("a"))
+
+		Expect(data).Should(ContainSubstring(`test_info{name="a",region="eu-central-1",external_name="ext-a"} 1`))
+		Expect(data).Should(ContainSubstring(`test_nodes{name="a",status="available"} 3`))
+		Expect(data).Should(ContainSubstring(`test_ready{name="a",reason="Available"} 1`))
+	})
+})
+
+// heapAlloc returns the allocated heap bytes after a garbage collection
+func heapAlloc() int64 {
+	var stats goruntime.MemStats
+	goruntime.GC()
+	goruntime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
+
+// BenchmarkInformerCache reports the heap retained per watched object by an informer caching the full objects
+// and by the informer and the metric store of the handler, which caches the pruned objects.
+func BenchmarkInformerCache(b *testing.B) {
+	const n = 500
+	objects := make([]k8sruntime.Object, n)
+	for i := range objects {
+		objects[i] = newManagedObject(fmt.Sprintf("object%d", i))
+	}
+	client := newFakeClient(objects...)
+
+	for _, t := range []struct {
+		name string
+		// run starts watching the objects, it returns after the initial list was cached and returns the func stopping the watch
+		run func() func()
+	}{
+		{name: "full", run: func() func() {
+			informer := cache.NewSharedIndexInformer(&cache.ListWatch{
+				ListFunc: func(ops metav1.ListOptions) (k8sruntime.Object, error) {
+					return client.Resource(testGVR).List(context.Background(), ops)
+				},
+				WatchFunc: func(ops metav1.ListOptions) (watch.Interface, error) {
+					return client.Resource(testGVR).Watch(context.Background(), ops)
+				},
+			}, &unstructured.Unstructured{}, 0, cache.Indexers{})
+			stop := make(chan struct{})
+			go informer.Run(stop)
+			cache.WaitForCacheSync(stop, informer.HasSynced)
+			return func() { close(stop) }
+		}},
+		{name: "handler", run: func() func() {
+			mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), "test", testGVR, "", pruneOptions)
+			for len(mmHandler.CachedObjects(testGVR)) < n || !strings.HasSuffix(serveData(&mmHandler), fmt.Sprintf(" %d\n", n)) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			return func() { close(channel) }
+		}},
+	} {
+		b.Run(t.name, func(b *testing.B) {
+			var total int64
+			for i := 0; i < b.N; i++ {
+				before := heapAlloc()
+				stop := t.run()
+				total += heapAlloc() - before
+				stop()
+			}
+			b.ReportMetric(float64(total)/float64(b.N)/n, "retained-B/obj")
+		})
+	}
+}
+
+// serveData returns the metrics served by the handler
+func serveData(mmHandler *handler.ManagedMetricsHandler) string {
+	w := store_test.ResponseWriterMock{}
+	mmHandler.ServeHTTP(&w, nil)
+	return w.Data
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	store "github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+// syncTracker holds the informers passing objects to each metric store.
+// A store migrating to another version is passed objects by the informers of both versions for a while.
+type syncTracker struct {
+	mutex     sync.Mutex
+	informers map[store.IXMetricsStore][]*trackedInformer
+}
+
+type trackedInformer struct {
+	gvr      schema.GroupVersionResource
+	informer *informerHandler
+	// namespaces filters the objects passed to stores selecting namespaces, it is nil for other stores
+	namespaces *store.NamespaceFilterStore
+}
+
+func newSyncTracker() *syncTracker {
+	return &syncTracker{
+		informers: map[store.IXMetricsStore][]*trackedInformer{},
+	}
+}
+
+// track adds the informer of the gvr to the store, the returned func removes it again
+func (t *syncTracker) track(metricStore store.IXMetricsStore, gvr schema.GroupVersionResource, informer *informerHandler, namespaces *store.NamespaceFilterStore) func() {
+	tracked := &trackedInformer{gvr: gvr, informer: informer, namespaces: namespaces}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.informers[metricStore] = append(t.informers[metricStore], tracked)
+
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		informers := t.informers[metricStore]
+		for i, informer := range informers {
+			if informer == tracked {
+				informers = append(informers[:i], informers[i+1:]...)
+				break
+			}
+		}
+		if len(informers) == 0 {
+			delete(t.informers, metricStore)
+			return
+		}
+		t.informers[metricStore] = informers
+	}
+}
+
+// unsynced returns the resources of the informers of the store, which did not complete their initial list yet
+func (t *syncTracker) unsynced(metricStore store.IXMetricsStore) []schema.GroupVersionResource {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var gvrs []schema.GroupVersionResource
+	for _, informer := range t.informers[metricStore] {
+		if !informer.informer.hasSynced() {
+			gvrs = append(gvrs, informer.gvr)
+		}
+	}
+	return gvrs
+}
+
+// UnsyncedResources returns the resources of the informers of the metric store, which did not complete their initial list yet.
+// Scrapes before would miss their objects. Stores without an informer, because their options are invalid, have nothing to wait for.
+func (m *ManagedMetricsHandler) UnsyncedResources(metricName string) []schema.GroupVersionResource {
+	metricStore, ok := m.registry.Get(metricName)
+	if !ok {
+		return nil
+	}
+	return m.syncs.unsynced(metricStore)
+}
+
+// setNamespaces sets the namespaces of the informers of the store and replaces its objects with the objects of these namespaces.
+// It returns false, if the store does not select namespaces.
+func (t *syncTracker) setNamespaces(metricStore store.IXMetricsStore, namespaces []string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	informers := t.informers[metricStore]
+	if len(informers) == 0 {
+		return false
+	}
+	for _, informer := range informers {
+		if informer.namespaces == nil {
+			return false
+		}
+	}
+	for _, informer := range informers {
+		informer.namespaces.SetNamespaces(namespaces)
+		go informer.informer.replaceAfterSync()
+	}
+	return true
+}
+
+// UpdateNamespaces changes the namespaces of a metric store selecting namespaces, e.g. after a namespace selector matches other namespaces.
+// The objects of the store are replaced with the cached objects of these namespaces, the series of namespaces still selected are kept
+// and nothing is listed again. It returns false, if there is no such store.
+func (m *ManagedMetricsHandler) UpdateNamespaces(metricName string, namespaces []string) bool {
+	metricStore, ok := m.registry.Get(metricName)
+	if !ok {
+		return false
+	}
+	return m.syncs.setNamespaces(metricStore, namespaces)
+}
@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+var _ = Describe("Readiness", func() {
+	It("Should report the resources of a store until their initial list completed", func(ctx SpecContext) {
+		client := newFakeClient(newTestObject("a", map[string]interface{}{})).(*fake.FakeDynamicClient)
+		listed := make(chan struct{})
+		client.PrependReactor("list", "objects", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			<-listed
+			return false, nil, nil
+		})
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+		Expect(mmHandler.UnsyncedResources("test")).Should(BeEmpty())
+
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+		defer close(channel)
+		Expect(mmHandler.UnsyncedResources("test")).Should(Equal([]schema.GroupVersionResource{testGVR}))
+
+		close(listed)
+		Eventually(mmHandler.UnsyncedResources).WithArguments("test").Should(BeEmpty())
+	})
+	It("Should not report stopped stores", func(ctx SpecContext) {
+		client := newFakeClient().(*fake.FakeDynamicClient)
+		listed := make(chan struct{})
+		defer close(listed)
+		client.PrependReactor("list", "objects", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			<-listed
+			return false, nil, nil
+		})
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(client, store.NewXMetricsStore)
+		channel := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, "test", testGVR, "", handler.StoreOptions{})
+		Expect(mmHandler.UnsyncedResources("test")).ShouldNot(BeEmpty())
+
+		close(channel)
+		Eventually(mmHandler.UnsyncedResources).WithArguments("test").Should(BeEmpty())
+	})
+})
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	store "github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+type registryEntry struct {
+	store    store.IXMetricsStore
+	callback func() (schema.GroupVersionResource, int)
+}
+
+// MetricStoreRegistry holds the metric stores of the handler by metric name.
+// It is safe for concurrent use by scrapes and reconcilers.
+type MetricStoreRegistry struct {
+	mutex   sync.RWMutex
+	entries map[string]registryEntry
+}
+
+func NewMetricStoreRegistry() *MetricStoreRegistry {
+	return &MetricStoreRegistry{
+		entries: map[string]registryEntry{},
+	}
+}
+
+// Add adds the metric store under the name, an existing store with the same name is replaced
+func (r *MetricStoreRegistry) Add(name string, metricStore store.IXMetricsStore) {
+	_, callback := metricStore.GetCallback()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries[name] = registryEntry{
+		store:    metricStore,
+		callback: callback,
+	}
+}
+
+// Get returns the metric store registered under the name
+func (r *MetricStoreRegistry) Get(name string) (store.IXMetricsStore, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	entry, ok := r.entries[name]
+	return entry.store, ok
+}
+
+// Remove removes the metric store registered under the name, unknown names are ignored
+func (r *MetricStoreRegistry) Remove(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.entries, name)
+}
+
+// Names returns the sorted names of all registered metric stores
+func (r *MetricStoreRegistry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteAll writes the metrics of the registered stores selected by the filter in the exposition format ordered by name
+// and returns the total number of selected objects.
+// Stores without objects in a namespace the reader is authorized for are skipped.
+func (r *MetricStoreRegistry) WriteAll(w io.Writer, format expfmt.Format, filter Filter) int {
+	// the stores are written without holding the lock, a scrape does not block adding and removing stores
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]registryEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, r.entries[name])
+	}
+	r.mutex.RUnlock()
+
+	total := 0
+	for i, entry := range entries {
+		gvr, _ := entry.callback()
+		if !filter.matchStore(names[i], gvr) {
+			continue
+		}
+		storeFilter := filter.Filter
+		if filter.authorize != nil {
+			authorized := filter.authorize(gvr, entry.store.Namespaces())
+			if authorized != nil && len(authorized) == 0 {
+				continue
+			}
+			storeFilter.Authorized = authorized
+		}
+		total += entry.store.WriteAll(w, format, storeFilter)
+	}
+	return total
+}
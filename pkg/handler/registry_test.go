@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	store_test "github.com/crossplane-contrib/x-metrics/pkg/handler/mock"
+)
+
+var _ = Describe("MetricStoreRegistry", func() {
+	It("Should write the stores ordered by name and sum their counts", func() {
+		registry := handler.NewMetricStoreRegistry()
+		registry.Add("b", &store_test.XMetricsStoreMock{Num: 2, WriteData: "b;"})
+		registry.Add("a", &store_test.XMetricsStoreMock{Num: 3, WriteData: "a;"})
+
+		w := store_test.ResponseWriterMock{}
+		Expect(registry.WriteAll(&w, expfmt.FmtText, handler.Filter{})).Should(Equal(5))
+		Expect(w.Data).Should(Equal("a;b;"))
+		Expect(registry.Names()).Should(Equal([]string{"a", "b"}))
+	})
+	It("Should only write the stores selected by metric and group", func() {
+		registry := handler.NewMetricStoreRegistry()
+		registry.Add("a", &store_test.XMetricsStoreMock{Num: 1, WriteData: "a;", GVR: schema.GroupVersionResource{Group: "ec2.aws.upbound.io"}})
+		registry.Add("b", &store_test.XMetricsStoreMock{Num: 2, WriteData: "b;", GVR: schema.GroupVersionResource{Group: "rds.aws.upbound.io"}})
+		registry.Add("c", &store_test.XMetricsStoreMock{Num: 4, WriteData: "c;", GVR: schema.GroupVersionResource{Group: "rds.aws.upbound.io"}})
+
+		w := store_test.ResponseWriterMock{}
+		Expect(registry.WriteAll(&w, expfmt.FmtText, handler.Filter{Groups: []string{"rds.aws.upbound.io"}})).Should(Equal(6))
+		Expect(w.Data).Should(Equal("b;c;"))
+
+		w = store_test.ResponseWriterMock{}
+		Expect(registry.WriteAll(&w, expfmt.FmtText, handler.Filter{Metrics: []string{"a", "c"}, Groups: []string{"rds.aws.upbound.io"}})).Should(Equal(4))
+		Expect(w.Data).Should(Equal("c;"))
+	})
+	It("Should replace and remove stores", func() {
+		registry := handler.NewMetricStoreRegistry()
+		registry.Add("a", &store_test.XMetricsStoreMock{Num: 3, WriteData: "old;"})
+		registry.Add("a", &store_test.XMetricsStoreMock{Num: 1, WriteData: "new;"})
+
+		w := store_test.ResponseWriterMock{}
+		Expect(registry.WriteAll(&w, expfmt.FmtText, handler.Filter{})).Should(Equal(1))
+		Expect(w.Data).Should(Equal("new;"))
+
+		registry.Remove("a")
+		registry.Remove("unknown")
+		_, ok := registry.Get("a")
+		Expect(ok).Should(BeFalse())
+		Expect(registry.Names()).Should(BeEmpty())
+	})
+	It("Should allow scrapes while stores are added and removed", func() {
+		registry := handler.NewMetricStoreRegistry()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					name := fmt.Sprintf("metric_%d_%d", i, j%5)
+					registry.Add(name, &store_test.XMetricsStoreMock{Num: 1, WriteData: name + ";"})
+					registry.Get(name)
+					registry.Remove(name)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					w := store_test.ResponseWriterMock{}
+					registry.WriteAll(&w, expfmt.FmtText, handler.Filter{})
+					registry.Names()
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(registry.Names()).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Handler concurrency", func() {
+	It("Should serve metrics while metric stores are registered and removed", func(ctx SpecContext) {
+		mmHandler := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), store_test.NewXMetricsStoreMockGenerator(1, ""))
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					name := fmt.Sprintf("metric_%d", i)
+					registered := mmHandler.RegisterAndAddMetricStoreForGVR(ctx, name, testGVR, "", handler.StoreOptions{})
+					migrated := mmHandler.MigrateMetricStore(ctx, name, testGVR, "", handler.StoreOptions{})
+					close(registered)
+					close(migrated)
+					mmHandler.RemoveMetricStore(name)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					w := store_test.ResponseWriterMock{}
+					mmHandler.ServeHTTP(&w, nil)
+				}
+			}()
+		}
+		wg.Wait()
+
+		w := store_test.ResponseWriterMock{}
+		mmHandler.ServeHTTP(&w, nil)
+		Expect(w.Data).Should(ContainSubstring("x_metric_resources_count_total 0"))
+	})
+})
@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// MetricsPath is the path the handler serves the metrics of all metric stores on.
+// The metrics of a single Metric or ClusterMetric are served below it, see ConsumerPath.
+const MetricsPath = "/x-metrics"
+
+// ConsumerLookup returns the names of the metric stores consumed by a Metric,
+// or by a ClusterMetric if the namespace is empty
+type ConsumerLookup func(namespace string, name string) []string
+
+// ConsumerPath returns the path serving only the metrics of a Metric,
+// or of a ClusterMetric if the namespace is empty
+func ConsumerPath(namespace string, name string) string {
+	if namespace == "" {
+		return path.Join(MetricsPath, "clustermetrics", name)
+	}
+	return path.Join(MetricsPath, "namespaces", namespace, "metrics", name)
+}
+
+// SetConsumerLookup sets the lookup of the metric stores served on the paths of the metric objects.
+// It has to be set before the handler serves requests.
+func (m *ManagedMetricsHandler) SetConsumerLookup(lookup ConsumerLookup) {
+	m.consumers = lookup
+}
+
+// objectRoute is the metric object of a path, the namespace of a ClusterMetric is empty
+type objectRoute struct {
+	namespace string
+	name      string
+}
+
+// parsePath returns the metric object of the path, or nil for the metrics of all stores.
+// ok is false for unknown paths.
+func parsePath(urlPath string) (route *objectRoute, ok bool) {
+	p := strings.Trim(strings.TrimPrefix(urlPath, MetricsPath), "/")
+	if p == "" {
+		return nil, true
+	}
+	switch segments := strings.Split(p, "/"); {
+	case len(segments) == 4 && segments[0] == "namespaces" && segments[1] != "" && segments[2] == "metrics" && segments[3] != "":
+		return &objectRoute{namespace: segments[1], name: segments[3]}, true
+	case len(segments) == 2 && segments[0] == "clustermetrics" && segments[1] != "":
+		return &objectRoute{name: segments[1]}, true
+	}
+	return nil, false
+}
+
+// route returns the names of the metric stores served on the path of the request.
+// All stores are served, if the returned names are nil. ok is false for unknown paths.
+func (m *ManagedMetricsHandler) route(r *http.Request) (stores []string, ok bool) {
+	if r == nil || r.URL == nil {
+		return nil, true
+	}
+	route, ok := parsePath(r.URL.Path)
+	if !ok || route == nil {
+		return nil, ok
+	}
+	if m.consumers == nil {
+		return nil, false
+	}
+	stores = m.consumers(route.namespace, route.name)
+	return stores, len(stores) > 0
+}
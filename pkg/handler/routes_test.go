@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/crossplane-contrib/x-metrics/pkg/handler"
+	"github.com/crossplane-contrib/x-metrics/pkg/store"
+)
+
+var _ = Describe("Metric object routes", func() {
+	var mmHandler *handler.ManagedMetricsHandler
+	BeforeEach(func() {
+		h := handler.NewManagedMetricsHandlerWithStore(newFakeClient(newTestObject("a", map[string]interface{}{})), store.NewXMetricsStore)
+		mmHandler = &h
+		mmHandler.SetConsumerLookup(func(namespace string, name string) []string {
+			switch {
+			case namespace == "team-a" && name == "objects":
+				return []string{"first"}
+			case namespace == "" && name == "all":
+				return []string{"first", "second"}
+			}
+			return nil
+		})
+		for _, name := range []string{"first", "second", "third"} {
+			channel := mmHandler.RegisterAndAddMetricStoreForGVR(context.Background(), name, testGVR, "", handler.StoreOptions{})
+			DeferCleanup(func() {
+				close(channel)
+			})
+		}
+		Eventually(func() string {
+			return getPath(mmHandler, handler.MetricsPath)
+		}).Should(HaveSuffix("x_metric_resources_count_total 3\n"))
+	})
+	It("Should build the paths of metric objects", func() {
+		Expect(handler.ConsumerPath("team-a", "objects")).Should(Equal("/x-metrics/namespaces/team-a/metrics/objects"))
+		Expect(handler.ConsumerPath("", "all")).Should(Equal("/x-metrics/clustermetrics/all"))
+	})
+	It("Should only serve the stores of a Metric", func() {
+		data := getPath(mmHandler, handler.ConsumerPath("team-a", "objects"))
+
+		Expect(data).Should(ContainSubstring("\nfirst_created{"))
+		Expect(data).ShouldNot(ContainSubstring("second"))
+		Expect(data).ShouldNot(ContainSubstring("third"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+	})
+	It("Should only serve the stores of a ClusterMetric", func() {
+		data := getPath(mmHandler, handler.ConsumerPath("", "all")+"/")
+
+		Expect(data).Should(ContainSubstring("\nfirst_created{"))
+		Expect(data).Should(ContainSubstring("\nsecond_created{"))
+		Expect(data).ShouldNot(ContainSubstring("third"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 2\n"))
+	})
+	It("Should apply query parameter filters to the stores of a metric object", func() {
+		data := getPath(mmHandler, handler.ConsumerPath("", "all")+"?metric=second,third")
+
+		Expect(data).ShouldNot(ContainSubstring("first"))
+		Expect(data).ShouldNot(ContainSubstring("third"))
+		Expect(data).Should(HaveSuffix("x_metric_resources_count_total 1\n"))
+	})
+	It("Should not find unknown metric objects and paths", func() {
+		for _, target := range []string{
+			handler.ConsumerPath("team-b", "objects"),
+			handler.ConsumerPath("", "unknown"),
+			"/x-metrics/namespaces/team-a/objects",
+			"/x-metrics/namespaces//metrics/objects",
+			"/x-metrics/unknown",
+		} {
+			w := httptest.NewRecorder()
+			mmHandler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			Expect(w.Code).Should(Equal(http.StatusNotFound), target)
+		}
+	})
+})
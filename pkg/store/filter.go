@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import "strings"
+
+// Filter selects the objects and metric families written by a metric store.
+// An empty field selects everything.
+type Filter struct {
+	// Namespaces selects the objects in one of the namespaces, cluster scoped objects are not selected
+	Namespaces []string
+	// Families selects the metric families by their name or by their name without the metric name, e.g. ready or synced_time
+	Families []string
+	// Authorized restricts the objects to the namespaces the reader is authorized for, if not nil.
+	// Cluster scoped objects are selected by the empty namespace.
+	Authorized []string
+}
+
+// empty returns true, if the filter selects everything
+func (f Filter) empty() bool {
+	return len(f.Namespaces) == 0 && len(f.Families) == 0 && f.Authorized == nil
+}
+
+func (f Filter) matchNamespace(namespace string) bool {
+	if f.Authorized != nil && !contains(f.Authorized, namespace) {
+		return false
+	}
+	return len(f.Namespaces) == 0 || contains(f.Namespaces, namespace)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchFamily(metricName string, family string) bool {
+	if len(f.Families) == 0 {
+		return true
+	}
+	suffix, hasPrefix := strings.CutPrefix(family, metricName+"_")
+	for _, name := range f.Families {
+		if name == family || (hasPrefix && name == suffix) {
+			return true
+		}
+	}
+	return false
+}
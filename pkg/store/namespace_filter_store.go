@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceFilterStore passes only objects of the given namespaces to the underlying store
+type NamespaceFilterStore struct {
+	cache.Store
+	mutex      sync.RWMutex
+	namespaces map[string]struct{}
+}
+
+func NewNamespaceFilterStore(store cache.Store, namespaces []string) *NamespaceFilterStore {
+	filter := &NamespaceFilterStore{
+		Store: store,
+	}
+	filter.SetNamespaces(namespaces)
+	return filter
+}
+
+// SetNamespaces changes the namespaces passed to the underlying store.
+// Objects already in the store are kept, they are removed by the next Replace.
+func (s *NamespaceFilterStore) SetNamespaces(namespaces []string) {
+	selected := make(map[string]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		selected[ns] = struct{}{}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.namespaces = selected
+}
+
+func (s *NamespaceFilterStore) matches(obj interface{}) bool {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.namespaces[o.GetNamespace()]
+	return ok
+}
+
+func (s *NamespaceFilterStore) Add(obj interface{}) error {
+	if !s.matches(obj) {
+		return nil
+	}
+	return s.Store.Add(obj)
+}
+
+func (s *NamespaceFilterStore) Update(obj interface{}) error {
+	if !s.matches(obj) {
+		return nil
+	}
+	return s.Store.Update(obj)
+}
+
+func (s *NamespaceFilterStore) Delete(obj interface{}) error {
+	if !s.matches(obj) {
+		return nil
+	}
+	return s.Store.Delete(obj)
+}
+
+// Replace will delete the contents of the store, using instead the
+// objects of the given list in the selected namespaces.
+func (s *NamespaceFilterStore) Replace(list []interface{}, resourceVersion string) error {
+	filtered := make([]interface{}, 0, len(list))
+	for _, obj := range list {
+		if s.matches(obj) {
+			filtered = append(filtered, obj)
+		}
+	}
+	return s.Store.Replace(filtered, resourceVersion)
+}
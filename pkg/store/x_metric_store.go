@@ -1,130 +1,422 @@
 package store
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"sync"
 
 	"github.com/google/uuid"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kube-state-metrics/v2/pkg/metric"
-	metricsstore "k8s.io/kube-state-metrics/v2/pkg/metrics_store"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type IXMetricsStore interface {
 	cache.Store
-	WriteAll(io.Writer)
+	WriteAll(io.Writer, expfmt.Format, Filter) int
+	Namespaces() []string
 	GetCallbacUid() string
 	GetCallback() (string, func() (schema.GroupVersionResource, int))
+	SetGVR(gvr schema.GroupVersionResource)
+}
+
+// FamilyHeader holds the TYPE and HELP lines of a metric family for each exposition format.
+// A family is only written in the formats with a header.
+type FamilyHeader struct {
+	// Name of the family in the text format, families are filtered by it
+	Name        string
+	Text        string
+	OpenMetrics string
 }
 
 type XMetricsStore struct {
-	metricStore metricsstore.MetricsStore
-	counter     int
+	headers      []FamilyHeader
+	generateFunc func(interface{}) []metric.FamilyInterface
+	// metrics holds the rendered metric families of each object, aligned with the headers
+	metrics map[types.UID][][]byte
+	// objects indexes the namespace and status of each object in the metric store, the counts are derived from it
+	objects     map[types.UID]objectSummary
 	gvr         schema.GroupVersionResource
 	mutex       sync.RWMutex
 	metricaName string
 	callbackUid string
+
+	// cached enables the cache of the serialized metrics of unfiltered scrapes
+	cached bool
+	// output caches the serialized metrics of each format, it is reset when objects change.
+	// The cache is filled by readers holding the read lock, so it is guarded by its own mutex.
+	output      map[expfmt.Format]cachedOutput
+	outputMutex sync.Mutex
+}
+
+// cachedOutput is the serialized metrics of the store and the number of objects in it
+type cachedOutput struct {
+	data []byte
+	// gzip is the data compressed as a gzip member, it is compressed by the first scrape accepting gzip
+	gzip  []byte
+	count int
+}
+
+// GzipWriter is implemented by the writers of gzip encoded responses.
+// Cached stores write their compressed output as a complete gzip member, instead of having it compressed again on every scrape.
+type GzipWriter interface {
+	io.Writer
+	WriteGzipMember(member []byte) error
+}
+
+// objectSummary is the part of an object needed to count it
+type objectSummary struct {
+	namespace string
+	status    string
 }
 
-func NewXMetricsStore(headers []string, generateFunc func(interface{}) []metric.FamilyInterface, ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource, metricName string) IXMetricsStore {
+// Counts of the objects in a metric store
+type Counts struct {
+	Total       int
+	ByNamespace map[string]int
+	ByStatus    map[string]int
+}
+
+func NewXMetricsStore(headers []FamilyHeader, generateFunc func(interface{}) []metric.FamilyInterface, _ context.Context, _ dynamic.Interface, _ string, gvr schema.GroupVersionResource, metricName string) IXMetricsStore {
 
-	store := &XMetricsStore{
-		metricStore: *metricsstore.NewMetricsStore(headers, generateFunc),
-		gvr:         gvr,
-		counter:     0,
-		metricaName: metricName,
+	return &XMetricsStore{
+		headers:      headers,
+		generateFunc: generateFunc,
+		metrics:      map[types.UID][][]byte{},
+		objects:      map[types.UID]objectSummary{},
+		gvr:          gvr,
+		metricaName:  metricName,
 	}
+}
 
-	store.init(ctx, client, namespace, gvr)
-	return store
+// NewCachedXMetricsStore returns a metric store, which keeps the serialized metrics of each exposition format.
+// The metrics are only serialized again after objects changed, filtered scrapes are not cached.
+// Scrapes are faster at the cost of the memory of the serialized metrics.
+func NewCachedXMetricsStore(headers []FamilyHeader, generateFunc func(interface{}) []metric.FamilyInterface, ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource, metricName string) IXMetricsStore {
+	s := NewXMetricsStore(headers, generateFunc, ctx, client, namespace, gvr, metricName).(*XMetricsStore)
+	s.cached = true
+	s.output = map[expfmt.Format]cachedOutput{}
+	return s
+}
 
+// invalidate marks the cached output as dirty, the caller has to hold the write lock
+func (s *XMetricsStore) invalidate() {
+	if s.cached && len(s.output) > 0 {
+		s.output = map[expfmt.Format]cachedOutput{}
+	}
 }
 
-func (s *XMetricsStore) init(ctx context.Context, client dynamic.Interface, namespace string, gvr schema.GroupVersionResource) {
-	log := log.FromContext(ctx)
-	o, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+// render generates the metric families of the object
+func (s *XMetricsStore) render(obj interface{}) [][]byte {
+	families := s.generateFunc(obj)
+	rendered := make([][]byte, len(families))
+	for i, f := range families {
+		rendered[i] = f.ByteSlice()
+	}
+	return rendered
+}
+
+// summarize returns the uid and the summary of the object.
+// The status is the status of the Ready condition, objects without it are counted as Unknown.
+func summarize(obj interface{}) (types.UID, objectSummary, error) {
+	o, err := meta.Accessor(obj)
 	if err != nil {
-		log.Info("err listing")
+		return "", objectSummary{}, err
+	}
+	summary := objectSummary{
+		namespace: o.GetNamespace(),
+		status:    string(corev1.ConditionUnknown),
 	}
-	s.counter = len(o.Items)
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != "Ready" {
+				continue
+			}
+			if status, ok := condition["status"].(string); ok && status != "" {
+				summary.status = status
+			}
+		}
+	}
+	return o.GetUID(), summary, nil
 }
 
 func (s *XMetricsStore) Add(obj interface{}) error {
-	s.counter++
-	return s.metricStore.Add(obj)
+	uid, summary, err := summarize(obj)
+	if err != nil {
+		return err
+	}
+	rendered := s.render(obj)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existing, ok := s.objects[uid]; ok && existing == summary && equalFamilies(s.metrics[uid], rendered) {
+		// resyncs and updates of fields without metrics keep the cached output
+		return nil
+	}
+	s.objects[uid] = summary
+	s.metrics[uid] = rendered
+	s.invalidate()
+	return nil
+}
+
+func equalFamilies(a [][]byte, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *XMetricsStore) Update(obj interface{}) error {
 	// TODO: For now, just call Add, in the future one could check if the resource version changed?
-	return s.metricStore.Update(obj)
+	return s.Add(obj)
 }
 
 func (s *XMetricsStore) Delete(obj interface{}) error {
-	s.counter--
-	return s.metricStore.Delete(obj)
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.objects[o.GetUID()]; !ok {
+		return nil
+	}
+	delete(s.objects, o.GetUID())
+	delete(s.metrics, o.GetUID())
+	s.invalidate()
+	return nil
 }
 
 // List implements the List method of the store interface.
+// Only the metrics of the objects are stored, so the list is empty.
 func (s *XMetricsStore) List() []interface{} {
-
-	return s.metricStore.List()
+	return nil
 }
 
 // ListKeys implements the ListKeys method of the store interface.
 func (s *XMetricsStore) ListKeys() []string {
-	return s.metricStore.ListKeys()
+	return nil
 }
 
 // Get implements the Get method of the store interface.
 func (s *XMetricsStore) Get(obj interface{}) (item interface{}, exists bool, err error) {
-	return s.metricStore.Get(obj)
+	return nil, false, nil
 }
 
 // GetByKey implements the GetByKey method of the store interface.
 func (s *XMetricsStore) GetByKey(key string) (item interface{}, exists bool, err error) {
-	return s.metricStore.GetByKey(key)
+	return nil, false, nil
 }
 
 // Replace will delete the contents of the store, using instead the
 // given list.
 func (s *XMetricsStore) Replace(list []interface{}, _ string) error {
-	return s.metricStore.Replace(list, "")
+	objects := make(map[types.UID]objectSummary, len(list))
+	metrics := make(map[types.UID][][]byte, len(list))
+	for _, obj := range list {
+		uid, summary, err := summarize(obj)
+		if err != nil {
+			return err
+		}
+		objects[uid] = summary
+		metrics[uid] = s.render(obj)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.objects = objects
+	s.metrics = metrics
+	s.invalidate()
+	return nil
 }
 
 // Resync implements the Resync method of the store interface.
 func (s *XMetricsStore) Resync() error {
-	return s.metricStore.Resync()
+	return nil
 }
 
-// WriteAll writes all metrics of the store into the given writer, zipped with the
-// help text of each metric family.
-func (s *XMetricsStore) WriteAll(w io.Writer) {
+// WriteAll writes the metrics of the objects and families selected by the filter in the exposition format into the given writer,
+// zipped with the header of each metric family. It returns the number of selected objects.
+// Unfiltered scrapes of a cached store are written from the cached output.
+// nolint: errcheck
+func (s *XMetricsStore) WriteAll(w io.Writer, format expfmt.Format, filter Filter) int {
+	if s.cached && filter.empty() {
+		if gz, ok := w.(GzipWriter); ok {
+			output := s.cachedOutput(format, true)
+			gz.WriteGzipMember(output.gzip)
+			return output.count
+		}
+		output := s.cachedOutput(format, false)
+		w.Write(output.data)
+		return output.count
+	}
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
+	return s.write(w, format, filter)
+}
 
-	s.metricStore.WriteAll(w)
-	s.writeCount(w)
+// cachedOutput returns the serialized metrics of the format, they are serialized, if the objects changed since the last scrape.
+// The compressed metrics are cached next to them, they are compressed on the first request after the objects changed.
+func (s *XMetricsStore) cachedOutput(format expfmt.Format, compressed bool) cachedOutput {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+	output, ok := s.output[format]
+	if !ok {
+		var buf bytes.Buffer
+		output.count = s.write(&buf, format, Filter{})
+		output.data = buf.Bytes()
+	}
+	if compressed && output.gzip == nil {
+		output.gzip = gzipMember(output.data)
+	}
+	s.output[format] = output
+	return output
+}
+
+// gzipMember returns the data compressed as a gzip member
+// nolint: errcheck
+func gzipMember(data []byte) []byte {
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	gz.Write(data)
+	gz.Close()
+	return buf.Bytes()
+}
+
+// write writes the metrics selected by the filter, the caller has to hold the lock
+// nolint: errcheck
+func (s *XMetricsStore) write(w io.Writer, format expfmt.Format, filter Filter) int {
+	// the objects are selected once, instead of for each family
+	selected := make([][][]byte, 0, len(s.metrics))
+	for uid, families := range s.metrics {
+		if filter.matchNamespace(s.objects[uid].namespace) {
+			selected = append(selected, families)
+		}
+	}
+	for i, header := range s.headers {
+		help := header.Text
+		if format == expfmt.FmtOpenMetrics {
+			help = header.OpenMetrics
+		}
+		if help == "" || !filter.matchFamily(s.metricaName, header.Name) {
+			continue
+		}
+		w.Write([]byte(help))
+		w.Write([]byte{'\n'})
+		for _, families := range selected {
+			if i < len(families) {
+				w.Write(families[i])
+			}
+		}
+	}
+	s.writeCount(w, filter)
+	return len(selected)
 }
 
 // nolint: errcheck
-func (s *XMetricsStore) writeCount(w io.Writer) {
+func (s *XMetricsStore) writeCount(w io.Writer, filter Filter) {
+	counts := s.counts(filter)
 	metricName := fmt.Sprintf("%s_resource_count", s.metricaName)
-	w.Write([]byte(fmt.Sprintf("# TYPE %[1]s gauge\n# HELP %[1]s A metrics series objects to count objects of %[2]s\n", metricName, s.metricaName)))
-	w.Write([]byte(metricName))
-	w.Write([]byte(" "))
-	w.Write([]byte(strconv.Itoa(s.counter)))
-	w.Write([]byte{'\n'})
+	if filter.matchFamily(s.metricaName, metricName) {
+		w.Write([]byte(fmt.Sprintf("# TYPE %[1]s gauge\n# HELP %[1]s A metrics series objects to count objects of %[2]s\n", metricName, s.metricaName)))
+		w.Write([]byte(metricName))
+		w.Write([]byte(" "))
+		w.Write([]byte(strconv.Itoa(counts.Total)))
+		w.Write([]byte{'\n'})
+	}
+	if filter.matchFamily(s.metricaName, metricName+"_by_namespace") {
+		writeCountBy(w, metricName+"_by_namespace", "namespace", fmt.Sprintf("Number of objects of %s per namespace", s.metricaName), counts.ByNamespace)
+	}
+	if filter.matchFamily(s.metricaName, metricName+"_by_status") {
+		writeCountBy(w, metricName+"_by_status", "status", fmt.Sprintf("Number of objects of %s per status of the Ready condition", s.metricaName), counts.ByStatus)
+	}
+}
+
+// nolint: errcheck
+func writeCountBy(w io.Writer, metricName string, label string, help string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.Write([]byte(fmt.Sprintf("# TYPE %[1]s gauge\n# HELP %[1]s %[2]s\n", metricName, help)))
+	for _, k := range keys {
+		w.Write([]byte(fmt.Sprintf("%s{%s=%q} %d\n", metricName, label, k, counts[k])))
+	}
+}
+
+// counts derives the counts from the objects in the store selected by the filter, the caller has to hold the lock
+func (s *XMetricsStore) counts(filter Filter) Counts {
+	counts := Counts{
+		ByNamespace: map[string]int{},
+		ByStatus:    map[string]int{},
+	}
+	for _, o := range s.objects {
+		if !filter.matchNamespace(o.namespace) {
+			continue
+		}
+		counts.Total++
+		// cluster scoped objects are only part of the total
+		if o.namespace != "" {
+			counts.ByNamespace[o.namespace]++
+		}
+		counts.ByStatus[o.status]++
+	}
+	return counts
+}
+
+// Namespaces returns the sorted namespaces of the objects in the store, cluster scoped objects have the empty namespace
+func (s *XMetricsStore) Namespaces() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	seen := map[string]struct{}{}
+	namespaces := []string{}
+	for _, o := range s.objects {
+		if _, ok := seen[o.namespace]; !ok {
+			seen[o.namespace] = struct{}{}
+			namespaces = append(namespaces, o.namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Counts returns the number of objects in the store in total, per namespace and per status
+func (s *XMetricsStore) Counts() Counts {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.counts(Filter{})
 }
 
 func (s *XMetricsStore) ConterAndType() (schema.GroupVersionResource, int) {
-	return s.gvr, s.counter
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.gvr, len(s.objects)
+}
+
+// SetGVR sets the resource of the objects in the store, after the store moved to another version of it
+func (s *XMetricsStore) SetGVR(gvr schema.GroupVersionResource) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.gvr = gvr
 }
 
 func (s *XMetricsStore) GetCallback() (string, func() (schema.GroupVersionResource, int)) {
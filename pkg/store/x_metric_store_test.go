@@ -0,0 +1,472 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/v2/pkg/metric"
+)
+
+func newObject(name string, namespace string, ready string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion("test.cloud/v1")
+	obj.SetKind("Object")
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	obj.SetUID(types.UID(namespace + "/" + name))
+	if ready != "" {
+		obj.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Synced", "status": "True"},
+				map[string]interface{}{"type": "Ready", "status": ready},
+			},
+		}
+	}
+	return obj
+}
+
+func newTestStore() *XMetricsStore {
+	headers := []FamilyHeader{
+		{Name: "test", Text: "# TYPE test gauge", OpenMetrics: "# TYPE test gauge"},
+		{Name: "test_ready", Text: "# TYPE test_ready gauge", OpenMetrics: "# TYPE test_ready gauge"},
+	}
+	return NewXMetricsStore(headers, func(obj interface{}) []metric.FamilyInterface {
+		name := obj.(*unstructured.Unstructured).GetName()
+		return []metric.FamilyInterface{
+			&metric.Family{Name: "test", Metrics: []*metric.Metric{{LabelKeys: []string{"name"}, LabelValues: []string{name}, Value: 1}}},
+			&metric.Family{Name: "test_ready", Metrics: []*metric.Metric{{LabelKeys: []string{"name"}, LabelValues: []string{name}, Value: 1}}},
+		}
+	}, context.TODO(), nil, "", schema.GroupVersionResource{Group: "test.cloud", Version: "v1", Resource: "objects"}, "test").(*XMetricsStore)
+}
+
+func TestCounts(t *testing.T) {
+	a := newObject("a", "ns1", "True")
+	b := newObject("b", "ns1", "False")
+	c := newObject("c", "ns2", "")
+	cluster := newObject("cluster", "", "True")
+
+	cases := map[string]struct {
+		reason string
+		ops    func(s *XMetricsStore) error
+		want   Counts
+	}{
+		"Empty": {
+			reason: "A new store should not count objects without listing them.",
+			ops:    func(s *XMetricsStore) error { return nil },
+			want:   Counts{Total: 0, ByNamespace: map[string]int{}, ByStatus: map[string]int{}},
+		},
+		"InitialList": {
+			reason: "The initial list of the reflector should count each object once.",
+			ops: func(s *XMetricsStore) error {
+				return s.Replace([]interface{}{a, b, c}, "1")
+			},
+			want: Counts{
+				Total:       3,
+				ByNamespace: map[string]int{"ns1": 2, "ns2": 1},
+				ByStatus:    map[string]int{"True": 1, "False": 1, "Unknown": 1},
+			},
+		},
+		"AddExisting": {
+			reason: "Adding an object twice should count it once.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Add(a); err != nil {
+					return err
+				}
+				return s.Add(a)
+			},
+			want: Counts{Total: 1, ByNamespace: map[string]int{"ns1": 1}, ByStatus: map[string]int{"True": 1}},
+		},
+		"Relist": {
+			reason: "A relist after a watch expired should replace the counted objects.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Replace([]interface{}{a, b}, "1"); err != nil {
+					return err
+				}
+				if err := s.Add(c); err != nil {
+					return err
+				}
+				return s.Replace([]interface{}{b, c}, "2")
+			},
+			want: Counts{
+				Total:       2,
+				ByNamespace: map[string]int{"ns1": 1, "ns2": 1},
+				ByStatus:    map[string]int{"False": 1, "Unknown": 1},
+			},
+		},
+		"RelistEmpty": {
+			reason: "A relist without objects should reset the counts.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Replace([]interface{}{a, b, c}, "1"); err != nil {
+					return err
+				}
+				return s.Replace([]interface{}{}, "2")
+			},
+			want: Counts{Total: 0, ByNamespace: map[string]int{}, ByStatus: map[string]int{}},
+		},
+		"DeleteUnknown": {
+			reason: "Deleting an object that is not in the store should not change the counts.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Add(a); err != nil {
+					return err
+				}
+				return s.Delete(b)
+			},
+			want: Counts{Total: 1, ByNamespace: map[string]int{"ns1": 1}, ByStatus: map[string]int{"True": 1}},
+		},
+		"UpdateStatus": {
+			reason: "An update should move the object to its new status.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Replace([]interface{}{newObject("a", "ns1", "False")}, "1"); err != nil {
+					return err
+				}
+				return s.Update(a)
+			},
+			want: Counts{Total: 1, ByNamespace: map[string]int{"ns1": 1}, ByStatus: map[string]int{"True": 1}},
+		},
+		"Resync": {
+			reason: "A resync should not change the counts.",
+			ops: func(s *XMetricsStore) error {
+				if err := s.Replace([]interface{}{a, cluster}, "1"); err != nil {
+					return err
+				}
+				return s.Resync()
+			},
+			want: Counts{Total: 2, ByNamespace: map[string]int{"ns1": 1}, ByStatus: map[string]int{"True": 2}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStore()
+			if err := tc.ops(s); err != nil {
+				t.Fatalf("\n%s\nops(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, s.Counts()); diff != "" {
+				t.Errorf("\n%s\nCounts(): -want, +got:\n%s", tc.reason, diff)
+			}
+			if _, count := s.ConterAndType(); count != tc.want.Total {
+				t.Errorf("\n%s\nConterAndType(): want %d, got %d", tc.reason, tc.want.Total, count)
+			}
+		})
+	}
+}
+
+func TestWriteAllCounts(t *testing.T) {
+	s := newTestStore()
+	if err := s.Replace([]interface{}{
+		newObject("a", "ns1", "True"),
+		newObject("b", "ns2", "True"),
+		newObject("c", "ns2", "False"),
+	}, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	s.WriteAll(w, expfmt.FmtText, Filter{})
+	for _, want := range []string{
+		"\ntest_resource_count 3\n",
+		"\ntest_resource_count_by_namespace{namespace=\"ns1\"} 1\ntest_resource_count_by_namespace{namespace=\"ns2\"} 2\n",
+		"\ntest_resource_count_by_status{status=\"False\"} 1\ntest_resource_count_by_status{status=\"True\"} 2\n",
+	} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("WriteAll(...): want %q in:\n%s", want, w.String())
+		}
+	}
+}
+
+func TestWriteAllFilter(t *testing.T) {
+	objects := []interface{}{
+		newObject("a", "ns1", "True"),
+		newObject("b", "ns2", "True"),
+		newObject("c", "ns2", "False"),
+		newObject("cluster", "", "True"),
+	}
+
+	cases := map[string]struct {
+		reason   string
+		filter   Filter
+		want     int
+		contains []string
+		excludes []string
+	}{
+		"All": {
+			reason:   "An empty filter should write all objects and families.",
+			filter:   Filter{},
+			want:     4,
+			contains: []string{"\ntest{name=\"cluster\"} 1\n", "\ntest_ready{name=\"a\"} 1\n", "\ntest_resource_count 4\n"},
+		},
+		"Namespace": {
+			reason:   "Only the objects in the namespace should be written and counted.",
+			filter:   Filter{Namespaces: []string{"ns2"}},
+			want:     2,
+			contains: []string{"\ntest{name=\"b\"} 1\n", "\ntest_ready{name=\"c\"} 1\n", "\ntest_resource_count 2\n"},
+			excludes: []string{"name=\"a\"", "name=\"cluster\"", "namespace=\"ns1\""},
+		},
+		"FamilySuffix": {
+			reason:   "A family should be selected by its name without the metric name.",
+			filter:   Filter{Families: []string{"ready"}},
+			want:     4,
+			contains: []string{"# TYPE test_ready gauge\n", "\ntest_ready{name=\"a\"} 1\n"},
+			excludes: []string{"# TYPE test gauge", "test{", "test_resource_count"},
+		},
+		"FamilyName": {
+			reason:   "A family should be selected by its full name.",
+			filter:   Filter{Families: []string{"test", "test_resource_count"}},
+			want:     4,
+			contains: []string{"# TYPE test gauge\n", "\ntest_resource_count 4\n"},
+			excludes: []string{"test_ready", "test_resource_count_by_"},
+		},
+		"Authorized": {
+			reason:   "Only the objects in namespaces the reader is authorized for should be written and counted.",
+			filter:   Filter{Namespaces: []string{"ns1", "ns2"}, Authorized: []string{"ns2", ""}},
+			want:     2,
+			contains: []string{"\ntest{name=\"b\"} 1\n", "\ntest_resource_count 2\n"},
+			excludes: []string{"name=\"a\"", "name=\"cluster\"", "namespace=\"ns1\""},
+		},
+		"UnauthorizedAll": {
+			reason:   "No object should be written, if the reader is authorized for no namespace.",
+			filter:   Filter{Authorized: []string{}},
+			want:     0,
+			contains: []string{"\ntest_resource_count 0\n"},
+			excludes: []string{"name="},
+		},
+		"UnknownNamespace": {
+			reason:   "No object should be written for an unknown namespace.",
+			filter:   Filter{Namespaces: []string{"unknown"}},
+			want:     0,
+			contains: []string{"# TYPE test gauge\n# TYPE test_ready gauge\n", "\ntest_resource_count 0\n"},
+			excludes: []string{"name="},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStore()
+			if err := s.Replace(objects, "1"); err != nil {
+				t.Fatal(err)
+			}
+			w := &bytes.Buffer{}
+			if got := s.WriteAll(w, expfmt.FmtText, tc.filter); got != tc.want {
+				t.Errorf("\n%s\nWriteAll(...): want %d objects, got %d", tc.reason, tc.want, got)
+			}
+			for _, want := range tc.contains {
+				if !strings.Contains(w.String(), want) {
+					t.Errorf("\n%s\nWriteAll(...): want %q in:\n%s", tc.reason, want, w.String())
+				}
+			}
+			for _, exclude := range tc.excludes {
+				if strings.Contains(w.String(), exclude) {
+					t.Errorf("\n%s\nWriteAll(...): want no %q in:\n%s", tc.reason, exclude, w.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	s := newTestStore()
+	if err := s.Replace([]interface{}{
+		newObject("a", "ns2", "True"),
+		newObject("b", "ns1", "True"),
+		newObject("c", "ns2", "False"),
+		newObject("cluster", "", "True"),
+	}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"", "ns1", "ns2"}, s.Namespaces()); diff != "" {
+		t.Errorf("Namespaces(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestCachedOutput(t *testing.T) {
+	newCachedTestStore := func() *XMetricsStore {
+		s := newTestStore()
+		return NewCachedXMetricsStore(s.headers, s.generateFunc, context.TODO(), nil, "", s.gvr, s.metricaName).(*XMetricsStore)
+	}
+	write := func(s *XMetricsStore, filter Filter) (string, int) {
+		w := &bytes.Buffer{}
+		n := s.WriteAll(w, expfmt.FmtText, filter)
+		return w.String(), n
+	}
+	a := newObject("a", "ns1", "True")
+	b := newObject("b", "ns2", "False")
+
+	cases := map[string]struct {
+		reason string
+		ops    func(s *XMetricsStore) error
+		// cached is true, if the output is expected to be cached after the ops
+		cached bool
+	}{
+		"Scrape": {
+			reason: "A scrape should fill the cache.",
+			ops:    func(s *XMetricsStore) error { return nil },
+			cached: true,
+		},
+		"Add": {
+			reason: "A new object should invalidate the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Add(b) },
+		},
+		"UpdateUnchanged": {
+			reason: "An update without changed metrics should keep the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Update(newObject("a", "ns1", "True")) },
+			cached: true,
+		},
+		"UpdateStatus": {
+			reason: "An update of the status should invalidate the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Update(newObject("a", "ns1", "False")) },
+		},
+		"DeleteUnknown": {
+			reason: "Deleting an object that is not in the store should keep the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Delete(b) },
+			cached: true,
+		},
+		"Delete": {
+			reason: "Deleting an object should invalidate the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Delete(a) },
+		},
+		"Relist": {
+			reason: "A relist should invalidate the cache.",
+			ops:    func(s *XMetricsStore) error { return s.Replace([]interface{}{a, b}, "2") },
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := newCachedTestStore()
+			if err := s.Replace([]interface{}{a}, "1"); err != nil {
+				t.Fatal(err)
+			}
+			write(s, Filter{})
+			if err := tc.ops(s); err != nil {
+				t.Fatalf("\n%s\nops(...): unexpected error: %v", tc.reason, err)
+			}
+			if cached := len(s.output) > 0; cached != tc.cached {
+				t.Errorf("\n%s\ncached: want %t, got %t", tc.reason, tc.cached, cached)
+			}
+
+			// the output of a cached store has to equal the output of a store without cache
+			uncached := newTestStore()
+			if err := uncached.Replace([]interface{}{a}, "1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.ops(uncached); err != nil {
+				t.Fatal(err)
+			}
+			want, wantCount := write(uncached, Filter{})
+			for i := 0; i < 2; i++ {
+				got, count := write(s, Filter{})
+				if diff := cmp.Diff(sortedLines(want), sortedLines(got)); diff != "" {
+					t.Errorf("\n%s\nWriteAll(...): -want, +got:\n%s", tc.reason, diff)
+				}
+				if count != wantCount {
+					t.Errorf("\n%s\nWriteAll(...): want %d objects, got %d", tc.reason, wantCount, count)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedOutputFiltered(t *testing.T) {
+	s := newTestStore()
+	s = NewCachedXMetricsStore(s.headers, s.generateFunc, context.TODO(), nil, "", s.gvr, s.metricaName).(*XMetricsStore)
+	if err := s.Replace([]interface{}{newObject("a", "ns1", "True"), newObject("b", "ns2", "True")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	w := &bytes.Buffer{}
+	if n := s.WriteAll(w, expfmt.FmtText, Filter{Namespaces: []string{"ns2"}}); n != 1 {
+		t.Errorf("WriteAll(...): want 1 object, got %d", n)
+	}
+	if strings.Contains(w.String(), `name="a"`) {
+		t.Errorf("WriteAll(...): want no object of ns1 in:\n%s", w.String())
+	}
+	if len(s.output) > 0 {
+		t.Errorf("WriteAll(...): filtered scrapes should not be cached")
+	}
+}
+
+// gzipMembers collects the gzip members written by a cached store
+type gzipMembers struct {
+	bytes.Buffer
+	members [][]byte
+}
+
+func (g *gzipMembers) WriteGzipMember(member []byte) error {
+	g.members = append(g.members, member)
+	return nil
+}
+
+func TestCachedGzipOutput(t *testing.T) {
+	s := newTestStore()
+	s = NewCachedXMetricsStore(s.headers, s.generateFunc, context.TODO(), nil, "", s.gvr, s.metricaName).(*XMetricsStore)
+	if err := s.Replace([]interface{}{newObject("a", "ns1", "True")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	scrape := func() []byte {
+		w := &gzipMembers{}
+		if n := s.WriteAll(w, expfmt.FmtText, Filter{}); n != 1 {
+			t.Errorf("WriteAll(...): want 1 object, got %d", n)
+		}
+		if w.Len() > 0 || len(w.members) != 1 {
+			t.Fatalf("WriteAll(...): want one gzip member and no uncompressed data, got %d members and %d bytes", len(w.members), w.Len())
+		}
+		return w.members[0]
+	}
+	plain := &bytes.Buffer{}
+	s.WriteAll(plain, expfmt.FmtText, Filter{})
+
+	member := scrape()
+	reader, err := gzip.NewReader(bytes.NewReader(member))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(plain.String(), string(data)); diff != "" {
+		t.Errorf("WriteAll(...): -want, +got:\n%s", diff)
+	}
+	if again := scrape(); &again[0] != &member[0] {
+		t.Errorf("WriteAll(...): want the cached gzip member on the next scrape")
+	}
+
+	if err := s.Add(newObject("b", "ns2", "True")); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.output) > 0 {
+		t.Errorf("Add(...): want the cached output and gzip member invalidated")
+	}
+}
+
+// sortedLines returns the sorted lines of the exposition, the order of the objects in a family is not stable
+func sortedLines(data string) []string {
+	lines := strings.Split(data, "\n")
+	sort.Strings(lines)
+	return lines
+}